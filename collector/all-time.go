@@ -16,62 +16,66 @@ limitations under the License.
 package collector
 
 import (
+	"context"
 	"errors"
-	"io"
-	"net/url"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
 )
 
 const (
 	allTimeCollector = "all-time"
 	allTimeSubsystem = "cumulative"
-	allTimeEndpoint  = "all_time_since_today"
 )
 
 type alltimeCollector struct {
-	total     *prometheus.Desc
-	uri       url.URL
-	fetchStat func(url.URL, string, url.Values) (io.ReadCloser, error)
-	logger    log.Logger
+	total  *prometheus.Desc
+	client *wakatime.Client
+	logger log.Logger
 }
 
+var (
+	allTimeCacheTTL = collectorFlag(allTimeCollector, "cache-ttl",
+		"How long to serve all-time stats from cache before querying Wakatime again (0 disables caching).",
+	).Default("0s").Duration()
+	allTimePollInterval = collectorFlag(allTimeCollector, "poll-interval",
+		"How often to query all-time stats in background polling mode.",
+	).Default("6h").Duration()
+)
+
 func init() {
 	registerCollector(allTimeCollector, defaultEnabled, NewAllTimeCollector)
+	registerPollInterval(allTimeCollector, allTimePollInterval)
+	registerUserEndpoint(allTimeCollector, wakatime.AllTimeSinceTodayEndpoint)
 }
 
 // NewAllTimeCollector returns a new Collector exposing all-time stats.
 func NewAllTimeCollector(in CommonInputs, logger log.Logger) (Collector, error) {
+	cacheTTL, err := in.durationOption(allTimeCollector, "cache-ttl", *allTimeCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	return &alltimeCollector{
 		total: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, allTimeSubsystem, "seconds_total"),
 			"Total seconds (all time).",
 			nil, nil,
 		),
-		uri:       in.URI,
-		fetchStat: FetchHTTP(in.Token, in.SSLVerify, in.Timeout, logger),
-		logger:    logger,
+		client: newClient(in, cachedFetch(in.Cache, cacheTTL, FetchHTTP(in, logger)), logger),
+		logger: logger,
 	}, nil
 }
 
-func (c *alltimeCollector) Update(ch chan<- prometheus.Metric) error {
-	params := url.Values{}
-	params.Add("cache", "false")
-
-	body, fetchErr := c.fetchStat(c.uri, allTimeEndpoint, params)
-	if fetchErr != nil {
-		return fetchErr
-	}
-
-	alltimeStats := wakatimeAlltime{}
-	if err := ReadAndUnmarshal(body, &alltimeStats); err != nil {
+func (c *alltimeCollector) Update(ctx context.Context, ch chan<- prometheus.Metric) error {
+	alltimeStats, err := c.client.AllTimeSinceToday(ctx, wakatime.AllTimeSinceTodayOptions{NoCache: true})
+	if err != nil {
 		return err
 	}
 
-	defer body.Close()
-
 	level.Info(c.logger).Log(
 		"msg", "Collecting all-time from Wakatime",
 		"IsUpToDate", alltimeStats.Data.IsUpToDate,
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authorizer adds credentials to requests sent to Wakatime.
+type Authorizer interface {
+	Authorize(ctx context.Context, req *http.Request) error
+}
+
+// Invalidator is implemented by Authorizers whose credentials can be renewed
+// after Wakatime rejects them.
+type Invalidator interface {
+	// Invalidate marks the credentials added to req as rejected, and reports
+	// whether the request should be authorized and sent again.
+	Invalidate(req *http.Request) bool
+}
+
+// BasicAuth authorizes requests with a Wakatime API key, sent using HTTP
+// Basic authentication.
+type BasicAuth struct {
+	APIKey string
+}
+
+// Authorize implements the Authorizer interface.
+func (a BasicAuth) Authorize(ctx context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(a.APIKey)))
+	return nil
+}
+
+// APIKeyFile authorizes requests with a Wakatime API key read from a file,
+// sent using HTTP Basic authentication. The file is read again whenever it
+// changes, so that keys can be rotated without restarting the exporter. It
+// is safe for concurrent use.
+type APIKeyFile struct {
+	path  string
+	parse func([]byte) (string, error)
+
+	mtx     sync.Mutex
+	key     string
+	modTime time.Time
+	size    int64
+}
+
+// NewAPIKeyFile returns an APIKeyFile for a file containing only the API key.
+func NewAPIKeyFile(path string) (*APIKeyFile, error) {
+	return newAPIKeyFile(path, func(data []byte) (string, error) {
+		return strings.TrimSpace(string(data)), nil
+	})
+}
+
+// NewCLIConfigAPIKey returns an APIKeyFile for the api_key setting in a
+// wakatime CLI config file.
+func NewCLIConfigAPIKey(path string) (*APIKeyFile, error) {
+	return newAPIKeyFile(path, func(data []byte) (string, error) {
+		return ParseCLIConfig(data).APIKey, nil
+	})
+}
+
+func newAPIKeyFile(path string, parse func([]byte) (string, error)) (*APIKeyFile, error) {
+	a := &APIKeyFile{path: path, parse: parse}
+	if _, err := a.apiKey(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Authorize implements the Authorizer interface.
+func (a *APIKeyFile) Authorize(ctx context.Context, req *http.Request) error {
+	key, err := a.apiKey()
+	if err != nil {
+		return err
+	}
+	return BasicAuth{APIKey: key}.Authorize(ctx, req)
+}
+
+// apiKey returns the current API key, reading the file again if it has
+// changed since it was last read.
+func (a *APIKeyFile) apiKey() (string, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		if a.key != "" {
+			// Keep using the last key, e.g. while a secret is being replaced.
+			return a.key, nil
+		}
+		return "", fmt.Errorf("couldn't read API key file: %s", err)
+	}
+	if a.key != "" && fi.ModTime().Equal(a.modTime) && fi.Size() == a.size {
+		return a.key, nil
+	}
+
+	data, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read API key file: %s", err)
+	}
+	key, err := a.parse(data)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse API key file %s: %s", a.path, err)
+	}
+	if key == "" {
+		return "", fmt.Errorf("no API key found in %s", a.path)
+	}
+
+	a.key, a.modTime, a.size = key, fi.ModTime(), fi.Size()
+	return a.key, nil
+}
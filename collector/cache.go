@@ -0,0 +1,149 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheHitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "cache_hits_total",
+			Help:      "wakatime_exporter: Number of Wakatime responses served from cache.",
+		},
+		[]string{"endpoint"},
+	)
+	cacheMissesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "cache_misses_total",
+			Help:      "wakatime_exporter: Number of Wakatime responses not found in cache or expired.",
+		},
+		[]string{"endpoint"},
+	)
+	cacheAgeSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "cache_age_seconds",
+			Help:      "wakatime_exporter: Age of the most recently served Wakatime response.",
+		},
+		[]string{"endpoint"},
+	)
+)
+
+func init() {
+	registerExporterMetrics(cacheHitsTotal, cacheMissesTotal, cacheAgeSeconds)
+}
+
+// ResponseCache holds Wakatime API responses so that they can be served to
+// several scrapes without querying Wakatime each time, and revalidated using
+// conditional requests. It is safe for concurrent use.
+type ResponseCache struct {
+	mtx       sync.Mutex
+	entries   map[string]cacheEntry
+	validated map[string]validatedEntry
+}
+
+type cacheEntry struct {
+	body    []byte
+	fetched time.Time
+	ttl     time.Duration
+}
+
+// NewResponseCache returns an empty ResponseCache.
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{
+		entries:   make(map[string]cacheEntry),
+		validated: make(map[string]validatedEntry),
+	}
+}
+
+func (c *ResponseCache) get(key string, now time.Time) (cacheEntry, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.entries[key]
+	if !ok || e.expired(now) {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+// set stores e under key, and removes any expired entries, so that the
+// responses for targets which are no longer scraped do not stay in memory.
+func (c *ResponseCache) set(key string, e cacheEntry, now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for k, old := range c.entries {
+		if old.expired(now) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = e
+}
+
+func (e cacheEntry) expired(now time.Time) bool {
+	return now.Sub(e.fetched) >= e.ttl
+}
+
+// cachedFetch wraps fetch so that each response is served from cache until
+// it is older than ttl. Caching is disabled if cache is nil or ttl is zero.
+func cachedFetch(cache *ResponseCache, ttl time.Duration, fetch FetchFunc) FetchFunc {
+	if cache == nil || ttl <= 0 {
+		return fetch
+	}
+	return func(ctx context.Context, uri url.URL, subPath string, params url.Values) (io.ReadCloser, error) {
+		key := uri
+		key.Path = path.Join(key.Path, subPath)
+		key.RawQuery = params.Encode()
+
+		now := time.Now()
+		if e, ok := cache.get(key.String(), now); ok {
+			cacheHitsTotal.WithLabelValues(subPath).Inc()
+			cacheAgeSeconds.WithLabelValues(subPath).Set(now.Sub(e.fetched).Seconds())
+			return ioutil.NopCloser(bytes.NewReader(e.body)), nil
+		}
+		cacheMissesTotal.WithLabelValues(subPath).Inc()
+
+		body, err := fetch(ctx, uri, subPath, params)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, requestError(err)
+		}
+		cache.set(key.String(), cacheEntry{body: data, fetched: now, ttl: ttl}, now)
+		cacheAgeSeconds.WithLabelValues(subPath).Set(0)
+
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	c := NewResponseCache()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c.set("alice", cacheEntry{body: []byte("a"), fetched: start, ttl: time.Minute}, start)
+	c.set("bob", cacheEntry{body: []byte("b"), fetched: start, ttl: time.Hour}, start)
+	if e, ok := c.get("alice", start.Add(59*time.Second)); !ok || string(e.body) != "a" {
+		t.Errorf("got %q, %v before the TTL", e.body, ok)
+	}
+	if _, ok := c.get("alice", start.Add(time.Minute)); ok {
+		t.Error("got an entry after the TTL")
+	}
+
+	// Storing another entry removes the expired ones.
+	now := start.Add(2 * time.Minute)
+	c.set("carol", cacheEntry{body: []byte("c"), fetched: now, ttl: time.Minute}, now)
+	if _, ok := c.entries["alice"]; ok {
+		t.Error("expired entry was not removed")
+	}
+	for _, key := range []string{"bob", "carol"} {
+		if _, ok := c.get(key, now); !ok {
+			t.Errorf("entry %s was removed before its TTL", key)
+		}
+	}
+}
+
+func TestResponseCacheValidatedLimit(t *testing.T) {
+	c := NewResponseCache()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= maxValidatedEntries; i++ {
+		c.addValidated(fmt.Sprint(i), validatedEntry{etag: "e", stored: start.Add(time.Duration(i) * time.Second)})
+	}
+	if len(c.validated) != maxValidatedEntries {
+		t.Errorf("got %d entries, want %d", len(c.validated), maxValidatedEntries)
+	}
+	if _, ok := c.validated["0"]; ok {
+		t.Error("the least recently stored entry was not dropped")
+	}
+	if _, ok := c.validated[fmt.Sprint(maxValidatedEntries)]; !ok {
+		t.Error("the new entry was not stored")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CLIConfig holds the settings shared with the wakatime CLI and editor plugins.
+type CLIConfig struct {
+	APIKey string
+	APIURL string
+}
+
+// DefaultCLIConfigPath returns the location of the wakatime CLI config file,
+// which is .wakatime.cfg in $WAKATIME_HOME or the user's home directory.
+func DefaultCLIConfigPath() (string, error) {
+	if home := os.Getenv("WAKATIME_HOME"); home != "" {
+		return filepath.Join(home, ".wakatime.cfg"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".wakatime.cfg"), nil
+}
+
+// ParseCLIConfig reads the [settings] section of a wakatime CLI config file.
+func ParseCLIConfig(data []byte) CLIConfig {
+	var (
+		cfg     CLIConfig
+		section string
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != "settings" {
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "api_key", "apikey":
+			cfg.APIKey = value
+		case "api_url":
+			cfg.APIURL = value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var coalescedScrapesTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "coalesced_scrapes_total",
+		Help:      "wakatime_exporter: Number of scrapes which were served by a concurrent scrape of the same collectors.",
+	},
+)
+
+func init() {
+	registerExporterMetrics(coalescedScrapesTotal)
+}
+
+// scrapes coalesces concurrent scrapes across all WakaCollectors.
+var scrapes = &scrapeGroup{}
+
+// scrapeGroup runs at most one scrape per key at a time. Scrapes started
+// while another scrape with the same key is in flight wait for it and share
+// its result.
+type scrapeGroup struct {
+	mtx   sync.Mutex
+	calls map[string]*scrapeCall
+}
+
+type scrapeCall struct {
+	done    chan struct{}
+	metrics []prometheus.Metric
+	// waiters is the number of scrapes waiting for the result. The scrape is
+	// canceled once all of them have given up.
+	waiters int
+	ctx     *scrapeContext
+}
+
+// do runs scrape unless a scrape with the same key is already in flight, and
+// returns its metrics. shared is true if the metrics came from another scrape.
+//
+// The scrape runs on its own context, whose deadline is the latest deadline
+// of the scrapes waiting for it, and which is canceled once the contexts of
+// all of them are done. If ctx is done while other scrapes are still waiting,
+// do returns no metrics; the last scrape to give up waits for the canceled
+// scrape to return.
+func (g *scrapeGroup) do(ctx context.Context, key string, scrape func(ctx context.Context) []prometheus.Metric) (metrics []prometheus.Metric, shared bool) {
+	g.mtx.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*scrapeCall)
+	}
+	c, shared := g.calls[key]
+	if shared {
+		c.ctx.extend(ctx)
+	} else {
+		c = &scrapeCall{done: make(chan struct{}), ctx: newScrapeContext(ctx)}
+		g.calls[key] = c
+		go func() {
+			c.metrics = scrape(c.ctx)
+			g.forget(key, c)
+			c.ctx.cancel(context.Canceled)
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	g.mtx.Unlock()
+
+	select {
+	case <-c.done:
+		return c.metrics, shared
+	case <-ctx.Done():
+	}
+
+	g.mtx.Lock()
+	c.waiters--
+	last := c.waiters == 0
+	if last {
+		// Later scrapes must not join a canceled scrape.
+		g.forgetLocked(key, c)
+		c.ctx.cancel(ctx.Err())
+	}
+	g.mtx.Unlock()
+	if !last {
+		return nil, shared
+	}
+	<-c.done
+	return c.metrics, shared
+}
+
+// forget removes c from the scrapes in flight, unless it was already replaced.
+func (g *scrapeGroup) forget(key string, c *scrapeCall) {
+	g.mtx.Lock()
+	g.forgetLocked(key, c)
+	g.mtx.Unlock()
+}
+
+func (g *scrapeGroup) forgetLocked(key string, c *scrapeCall) {
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
+
+// scrapeContext is the context of a scrape shared by several waiting scrapes.
+// Its deadline is the latest deadline of the waiters, if they all have one,
+// and it is not canceled when any of theirs are.
+type scrapeContext struct {
+	done chan struct{}
+
+	mtx sync.Mutex
+	// deadline is zero if some waiter has none.
+	deadline time.Time
+	timer    *time.Timer
+	err      error
+}
+
+// newScrapeContext returns a scrapeContext with the deadline of ctx, if any.
+func newScrapeContext(ctx context.Context) *scrapeContext {
+	c := &scrapeContext{done: make(chan struct{})}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.deadline = deadline
+		c.timer = time.AfterFunc(time.Until(deadline), c.expire)
+	}
+	return c
+}
+
+// extend moves the deadline of c to that of ctx, if it is later, or removes
+// it if ctx has none.
+func (c *scrapeContext) extend(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.deadline.IsZero() || c.err != nil {
+		return
+	}
+	if !ok {
+		c.deadline = time.Time{}
+		c.timer.Stop()
+		return
+	}
+	if deadline.After(c.deadline) {
+		c.deadline = deadline
+		c.timer.Reset(time.Until(deadline))
+	}
+}
+
+// expire cancels c if its deadline has passed. The timer may fire for an
+// earlier deadline if it raced with extend.
+func (c *scrapeContext) expire() {
+	c.mtx.Lock()
+	expired := !c.deadline.IsZero() && !time.Now().Before(c.deadline)
+	c.mtx.Unlock()
+	if expired {
+		c.cancel(context.DeadlineExceeded)
+	}
+}
+
+// cancel cancels c with err, unless it is already done.
+func (c *scrapeContext) cancel(err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	close(c.done)
+}
+
+// Deadline implements context.Context.
+func (c *scrapeContext) Deadline() (time.Time, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.deadline, !c.deadline.IsZero()
+}
+
+// Done implements context.Context.
+func (c *scrapeContext) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err implements context.Context.
+func (c *scrapeContext) Err() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.err
+}
+
+// Value implements context.Context. A shared scrape has no values.
+func (c *scrapeContext) Value(key interface{}) interface{} {
+	return nil
+}
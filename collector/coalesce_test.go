@@ -0,0 +1,205 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/MacroPower/wakatime_exporter/fakeserver"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+func TestCollectCoalesced(t *testing.T) {
+	var requests int32
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	fake := fakeserver.New(testAPIKey)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		arrived <- struct{}{}
+		<-release
+		fake.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	base, err := url.Parse(srv.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := CommonInputs{
+		BaseURI:    *base,
+		URI:        wakatime.UserPath(*base, wakatime.CurrentUser),
+		Token:      testAPIKey,
+		Timeout:    5 * time.Second,
+		Retry:      RetryPolicy{MaxAttempts: 1},
+		Credential: "coalesce-test",
+	}
+	c, err := NewWakaCollector(in, log.NewNopLogger(), leaderCollectorName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := testutil.ToFloat64(coalescedScrapesTotal)
+
+	// The first scrape starts the upstream request, and gives up while the
+	// second scrape is waiting for it.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan struct{})
+	go func() {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(c.WithContext(ctx))
+		_, _ = testutil.GatherAndCount(reg)
+		close(first)
+	}()
+	<-arrived
+
+	second := make(chan error)
+	go func() {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(c)
+		second <- testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP wakatime_leaderboard_rank Current rank of the user.
+# TYPE wakatime_leaderboard_rank gauge
+wakatime_leaderboard_rank 42
+# HELP wakatime_up wakatime_exporter: Whether all requests to Wakatime during the scrape succeeded.
+# TYPE wakatime_up gauge
+wakatime_up 1
+`), "wakatime_leaderboard_rank", "wakatime_up")
+	}()
+	for waiters := 0; waiters < 2; {
+		time.Sleep(time.Millisecond)
+		scrapes.mtx.Lock()
+		if call, ok := scrapes.calls[c.scrapeKey]; ok {
+			waiters = call.waiters
+		}
+		scrapes.mtx.Unlock()
+	}
+
+	cancel()
+	<-first
+	close(release)
+	if err := <-second; err != nil {
+		t.Error(err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("got %d upstream requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(coalescedScrapesTotal) - before; got != 1 {
+		t.Errorf("got %v coalesced scrapes, want 1", got)
+	}
+}
+
+func TestCollectCoalescedDeadline(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	base, err := url.Parse(srv.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := CommonInputs{
+		BaseURI:    *base,
+		URI:        wakatime.UserPath(*base, wakatime.CurrentUser),
+		Token:      testAPIKey,
+		Timeout:    5 * time.Second,
+		Retry:      RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Second},
+		Credential: "coalesce-deadline-test",
+	}
+	c, err := NewWakaCollector(in, log.NewNopLogger(), leaderCollectorName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := testutil.ToFloat64(retriesTotal.WithLabelValues(wakatime.LeadersEndpoint))
+
+	// The backoff is longer than the scrape timeout, so the request must not
+	// be retried.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c.WithContext(ctx))
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP wakatime_up wakatime_exporter: Whether all requests to Wakatime during the scrape succeeded.
+# TYPE wakatime_up gauge
+wakatime_up 0
+`), "wakatime_up"); err != nil {
+		t.Error(err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("got %d upstream requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(retriesTotal.WithLabelValues(wakatime.LeadersEndpoint)) - before; got != 0 {
+		t.Errorf("got %v retries, want 0", got)
+	}
+}
+
+func TestScrapeContext(t *testing.T) {
+	first, cancelFirst := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelFirst()
+	second, cancelSecond := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelSecond()
+
+	ctx := newScrapeContext(first)
+	ctx.extend(second)
+	ctx.extend(first)
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(mustDeadline(second)) {
+		t.Errorf("got deadline %s, %v, want the latest deadline of the waiters", deadline, ok)
+	}
+
+	// The shared context outlives the first waiter.
+	<-first.Done()
+	if err := ctx.Err(); err != nil {
+		t.Errorf("got error %v after the first deadline", err)
+	}
+	<-ctx.Done()
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if time.Now().Before(mustDeadline(second)) {
+		t.Error("context expired before the latest deadline")
+	}
+
+	// A waiter without a deadline removes it.
+	ctx = newScrapeContext(first)
+	ctx.extend(context.Background())
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("got a deadline after a waiter without one")
+	}
+	ctx.cancel(context.Canceled)
+	if err := ctx.Err(); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func mustDeadline(ctx context.Context) time.Time {
+	deadline, _ := ctx.Deadline()
+	return deadline
+}
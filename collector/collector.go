@@ -17,12 +17,16 @@ limitations under the License.
 package collector
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -44,6 +48,21 @@ var (
 		[]string{"collector"},
 		nil,
 	)
+	upDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "up"),
+		"wakatime_exporter: Whether all requests to Wakatime during the scrape succeeded.",
+		nil,
+		nil,
+	)
+	scrapeErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scrape",
+			Name:      "errors_total",
+			Help:      "wakatime_exporter: Number of failed collector scrapes, by reason.",
+		},
+		[]string{"collector", "reason"},
+	)
 )
 
 const (
@@ -54,16 +73,48 @@ const (
 var (
 	factories        = make(map[string]func(in CommonInputs, logger log.Logger) (Collector, error))
 	collectorState   = make(map[string]*bool)
-	forcedCollectors = map[string]bool{} // collectors which have been explicitly enabled or disabled
+	forcedCollectors = map[string]bool{}    // collectors which have been explicitly enabled or disabled
+	exporterMetrics  []prometheus.Collector // metrics about the exporter itself, shared by all collectors
 )
 
 // CommonInputs are the inputs needed to implement any Collector
 type CommonInputs struct {
-	BaseURI   url.URL
-	URI       url.URL
-	Token     string
-	SSLVerify bool
-	Timeout   time.Duration
+	BaseURI     url.URL
+	URI         url.URL
+	Token       string
+	SSLVerify   bool
+	Timeout     time.Duration
+	Retry       RetryPolicy
+	Cache       *ResponseCache
+	RateLimiter *RateLimiter
+	// Transport is shared by all requests to Wakatime. If nil, each collector
+	// creates its own transport using SSLVerify.
+	Transport http.RoundTripper
+	// Auth adds credentials to requests. If nil, Token is sent as an API key.
+	Auth Authorizer
+	// Credential names the credentials in Auth, so that scrapes of the same
+	// user with different credentials are kept apart.
+	Credential string
+	// MaxBodySize is the largest response body read from Wakatime, in bytes.
+	// Zero means no limit.
+	MaxBodySize int64
+	// RecordDir, if set, is a directory to which every response is saved.
+	RecordDir string
+	// ReplayDir, if set, is a directory from which responses saved via
+	// RecordDir are served instead of querying Wakatime.
+	ReplayDir string
+	// StrictDecode enables reporting of responses which do not match the
+	// expected schema.
+	StrictDecode bool
+	// Collectors, if not nil, are the collectors to enable instead of those
+	// enabled via flags.
+	Collectors []string
+	// CollectorOptions override the option flags of each collector, by
+	// collector and option name, e.g. "goal" and "max-pages".
+	CollectorOptions map[string]map[string]string
+	// OtherUser is set if URI is not the user owning the credentials, in
+	// which case collectors which only report on that user are skipped.
+	OtherUser bool
 }
 
 func registerCollector(collector string, isDefaultEnabled bool, factory func(in CommonInputs, logger log.Logger) (Collector, error)) {
@@ -78,21 +129,69 @@ func registerCollector(collector string, isDefaultEnabled bool, factory func(in
 	flagHelp := fmt.Sprintf("Enable the %s collector (default: %s).", collector, helpDefaultState)
 	defaultValue := fmt.Sprintf("%v", isDefaultEnabled)
 
-	reg, _ := regexp.Compile("[^a-zA-Z0-9]+")
-	envar := "WAKA_COLLECTOR_" + strings.ToUpper(reg.ReplaceAllString(collector, ""))
-
-	flag := kingpin.Flag(flagName, flagHelp).Default(defaultValue).Envar(envar).Action(collectorFlagAction(collector)).Bool()
+	flag := kingpin.Flag(flagName, flagHelp).Default(defaultValue).Envar(collectorEnvar(collector)).Action(collectorFlagAction(collector)).Bool()
 	collectorState[collector] = flag
 
 	factories[collector] = factory
 }
 
+// collectorFlag registers an option flag for the given collector, named
+// collector.<collector>.<name>, with a matching environment variable.
+func collectorFlag(collector, name, help string) *kingpin.FlagClause {
+	flagName := fmt.Sprintf("collector.%s.%s", collector, name)
+	envar := collectorEnvar(collector) + "_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+
+	if collectorOptions[collector] == nil {
+		collectorOptions[collector] = make(map[string]bool)
+	}
+	collectorOptions[collector][name] = true
+
+	return kingpin.Flag(flagName, help).Envar(envar)
+}
+
+func collectorEnvar(collector string) string {
+	reg, _ := regexp.Compile("[^a-zA-Z0-9]+")
+	return "WAKA_COLLECTOR_" + strings.ToUpper(reg.ReplaceAllString(collector, ""))
+}
+
+func registerExporterMetrics(metrics ...prometheus.Collector) {
+	exporterMetrics = append(exporterMetrics, metrics...)
+}
+
+// ExporterMetrics returns the long-lived metrics about the exporter itself,
+// e.g. request retries, which should be registered alongside a WakaCollector.
+func ExporterMetrics() []prometheus.Collector {
+	return exporterMetrics
+}
+
+func init() {
+	registerExporterMetrics(scrapeErrorsTotal)
+}
+
 // WakaCollector implements the prometheus.Collector interface.
 type WakaCollector struct {
 	Collectors map[string]Collector
+	// pollIntervals holds the interval of each collector in background
+	// polling mode.
+	pollIntervals map[string]time.Duration
+	// credential and uri name the credentials used and the user queried, for
+	// recording the status of each collector.
+	credential string
+	uri        string
+	scrapeKey  string
 	logger     log.Logger
 }
 
+// Names returns the names of all registered collectors, sorted.
+func Names() []string {
+	names := make([]string, 0, len(collectorState))
+	for name := range collectorState {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // DisableDefaultCollectors sets the collector state to false for all collectors which
 // have not been explicitly enabled on the command line.
 func DisableDefaultCollectors() {
@@ -117,20 +216,51 @@ func collectorFlagAction(collector string) func(ctx *kingpin.ParseContext) error
 
 // NewWakaCollector creates a new Collector.
 func NewWakaCollector(in CommonInputs, logger log.Logger, filters ...string) (*WakaCollector, error) {
+	isEnabled := func(collector string) bool { return *collectorState[collector] }
+	if in.Collectors != nil {
+		enabled := make(map[string]bool)
+		for _, collector := range in.Collectors {
+			if _, exist := collectorState[collector]; !exist {
+				return nil, fmt.Errorf("missing collector: %s", collector)
+			}
+			enabled[collector] = true
+		}
+		isEnabled = func(collector string) bool { return enabled[collector] }
+	}
+	if in.OtherUser {
+		enabled := isEnabled
+		isEnabled = func(collector string) bool { return enabled(collector) && !ownerOnly(collector) }
+	}
+	if err := validateOptions(in.CollectorOptions); err != nil {
+		return nil, err
+	}
+
 	f := make(map[string]bool)
 	for _, filter := range filters {
-		enabled, exist := collectorState[filter]
-		if !exist {
+		if _, exist := collectorState[filter]; !exist {
 			return nil, fmt.Errorf("missing collector: %s", filter)
 		}
-		if !*enabled {
+		if in.OtherUser && ownerOnly(filter) {
+			return nil, fmt.Errorf("collector %s only reports on the user owning the credentials", filter)
+		}
+		if !isEnabled(filter) {
 			return nil, fmt.Errorf("disabled collector: %s", filter)
 		}
 		f[filter] = true
 	}
 	collectors := make(map[string]Collector)
-	for key, enabled := range collectorState {
-		if *enabled {
+	pollIntervals := make(map[string]time.Duration)
+	for key := range collectorState {
+		if isEnabled(key) {
+			interval, err := in.durationOption(key, "poll-interval", *pollIntervalFlags[key])
+			if err != nil {
+				return nil, err
+			}
+			if interval <= 0 {
+				return nil, fmt.Errorf("poll interval of collector %s must be positive", key)
+			}
+			pollIntervals[key] = interval
+
 			collector, err := factories[key](in, log.With(logger, "collector", key))
 			if err != nil {
 				return nil, err
@@ -140,39 +270,122 @@ func NewWakaCollector(in CommonInputs, logger log.Logger, filters ...string) (*W
 			}
 		}
 	}
-	return &WakaCollector{Collectors: collectors, logger: logger}, nil
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	scrapeKey := in.Credential + " " + in.URI.String() + " " + strings.Join(names, ",")
+
+	return &WakaCollector{
+		Collectors:    collectors,
+		pollIntervals: pollIntervals,
+		credential:    in.Credential,
+		uri:           in.URI.String(),
+		scrapeKey:     scrapeKey,
+		logger:        logger,
+	}, nil
 }
 
 // Describe implements the prometheus.Collector interface.
 func (n WakaCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- upDesc
 }
 
 // Collect implements the prometheus.Collector interface.
 func (n WakaCollector) Collect(ch chan<- prometheus.Metric) {
+	n.collect(context.Background(), ch)
+}
+
+// WithContext returns a prometheus.Collector which collects from n, abandoning
+// any requests to Wakatime once ctx is done.
+func (n WakaCollector) WithContext(ctx context.Context) prometheus.Collector {
+	return contextCollector{WakaCollector: n, ctx: ctx}
+}
+
+// collect runs all collectors and sends their metrics to ch. Concurrent
+// collections for the same collectors and user share a single run, which is
+// abandoned once all of them are done.
+func (n WakaCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	metrics, shared := scrapes.do(ctx, n.scrapeKey, func(ctx context.Context) []prometheus.Metric {
+		return bufferMetrics(func(ch chan<- prometheus.Metric) {
+			n.run(ctx, ch)
+		})
+	})
+	if shared {
+		level.Debug(n.logger).Log("msg", "Coalesced scrape with a concurrent scrape", "key", n.scrapeKey)
+		coalescedScrapesTotal.Inc()
+	}
+	for _, m := range metrics {
+		ch <- m
+	}
+}
+
+func (n WakaCollector) run(ctx context.Context, ch chan<- prometheus.Metric) {
+	var upstreamFailed int32
 	wg := sync.WaitGroup{}
 	wg.Add(len(n.Collectors))
 	for name, c := range n.Collectors {
 		go func(name string, c Collector) {
-			execute(name, c, ch, n.logger)
+			if err := execute(ctx, n.credential, n.uri, name, c, ch, n.logger); isUpstreamError(err) {
+				atomic.StoreInt32(&upstreamFailed, 1)
+			}
 			wg.Done()
 		}(name, c)
 	}
 	wg.Wait()
+
+	up := 1.0
+	if atomic.LoadInt32(&upstreamFailed) == 1 {
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up)
+}
+
+// contextCollector binds a WakaCollector to the context of a single scrape.
+type contextCollector struct {
+	WakaCollector
+	ctx context.Context
+}
+
+// Collect implements the prometheus.Collector interface.
+func (n contextCollector) Collect(ch chan<- prometheus.Metric) {
+	n.collect(n.ctx, ch)
+}
+
+// bufferMetrics returns the metrics sent by collect.
+func bufferMetrics(collect func(ch chan<- prometheus.Metric)) []prometheus.Metric {
+	var metrics []prometheus.Metric
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		close(done)
+	}()
+	collect(ch)
+	close(ch)
+	<-done
+	return metrics
 }
 
-func execute(name string, c Collector, ch chan<- prometheus.Metric, logger log.Logger) {
+func execute(ctx context.Context, credential, uri, name string, c Collector, ch chan<- prometheus.Metric, logger log.Logger) error {
 	begin := time.Now()
-	err := c.Update(ch)
+	err := c.Update(ctx, ch)
 	duration := time.Since(begin)
+	recordStatus(ctx, credential, uri, name, duration, err)
 	var success float64
 
 	if err != nil {
 		if isNoDataError(err) {
 			level.Debug(logger).Log("msg", "collector returned no data", "name", name, "duration_seconds", duration.Seconds(), "err", err)
 		} else {
-			level.Error(logger).Log("msg", "collector failed", "name", name, "duration_seconds", duration.Seconds(), "err", err)
+			reason := errorReason(err)
+			level.Error(logger).Log("msg", "collector failed", "name", name, "duration_seconds", duration.Seconds(), "reason", reason, "err", err)
+			scrapeErrorsTotal.WithLabelValues(name, reason).Inc()
 		}
 		success = 0
 	} else {
@@ -181,12 +394,13 @@ func execute(name string, c Collector, ch chan<- prometheus.Metric, logger log.L
 	}
 	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
 	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+	return err
 }
 
 // Collector is the interface a collector has to implement.
 type Collector interface {
 	// Get new metrics and expose them via prometheus registry.
-	Update(ch chan<- prometheus.Metric) error
+	Update(ctx context.Context, ch chan<- prometheus.Metric) error
 }
 
 type typedDesc struct {
@@ -202,5 +416,5 @@ func (d *typedDesc) mustNewConstMetric(value float64, labels ...string) promethe
 var ErrNoData = errors.New("collector returned no data")
 
 func isNoDataError(err error) bool {
-	return err == ErrNoData
+	return errors.Is(err, ErrNoData)
 }
@@ -0,0 +1,278 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/MacroPower/wakatime_exporter/fakeserver"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+const testAPIKey = "waka_test"
+
+func TestMain(m *testing.M) {
+	// Apply the defaults of the collector flags.
+	if _, err := kingpin.CommandLine.Parse(nil); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestInputs returns inputs for collectors querying a fake Wakatime API
+// with the given API key.
+func newTestInputs(t *testing.T, apiKey string) CommonInputs {
+	srv := httptest.NewServer(fakeserver.New(testAPIKey))
+	t.Cleanup(srv.Close)
+
+	base, err := url.Parse(srv.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return CommonInputs{
+		BaseURI: *base,
+		URI:     wakatime.UserPath(*base, wakatime.CurrentUser),
+		Token:   apiKey,
+		Timeout: 5 * time.Second,
+		Retry:   RetryPolicy{MaxAttempts: 1},
+	}
+}
+
+func TestCollectors(t *testing.T) {
+	tests := []struct {
+		collector string
+		metrics   []string
+		want      string
+	}{
+		{
+			collector: summaryCollectorName,
+			metrics: []string{
+				"wakatime_seconds_total",
+				"wakatime_language_seconds_total",
+				"wakatime_machine_seconds_total",
+				"wakatime_project_seconds_total",
+			},
+			want: `
+# HELP wakatime_seconds_total Total seconds.
+# TYPE wakatime_seconds_total counter
+wakatime_seconds_total 7200
+# HELP wakatime_language_seconds_total Total seconds for each language.
+# TYPE wakatime_language_seconds_total counter
+wakatime_language_seconds_total{name="Go"} 3600
+wakatime_language_seconds_total{name="Markdown"} 1800
+wakatime_language_seconds_total{name="Python"} 1800
+# HELP wakatime_machine_seconds_total Total seconds for each machine.
+# TYPE wakatime_machine_seconds_total counter
+wakatime_machine_seconds_total{id="machine-1",name="laptop"} 7200
+# HELP wakatime_project_seconds_total Total seconds for each project.
+# TYPE wakatime_project_seconds_total counter
+wakatime_project_seconds_total{name="dotfiles"} 1800
+wakatime_project_seconds_total{name="wakatime_exporter"} 5400
+`,
+		},
+		{
+			collector: goalCollectorName,
+			metrics:   []string{"wakatime_goal_progress_seconds"},
+			want: `
+# HELP wakatime_goal_progress_seconds Progress towards the goal.
+# TYPE wakatime_goal_progress_seconds counter
+wakatime_goal_progress_seconds{delta="day",enabled="true",id="goal-1",ignore_zero_days="false",inverse="false",name="Code 2 hrs per day",snoozed="false",tweeting="false",type="coding"} 5400
+wakatime_goal_progress_seconds{delta="day",enabled="true",id="goal-3",ignore_zero_days="false",inverse="false",name="Code 1 hr per day on dotfiles",snoozed="false",tweeting="false",type="coding"} 900
+wakatime_goal_progress_seconds{delta="week",enabled="true",id="goal-2",ignore_zero_days="false",inverse="false",name="Code 10 hrs per week in Go",snoozed="false",tweeting="false",type="coding"} 21600
+`,
+		},
+		{
+			collector: leaderCollectorName,
+			metrics:   []string{"wakatime_leaderboard_rank"},
+			want: `
+# HELP wakatime_leaderboard_rank Current rank of the user.
+# TYPE wakatime_leaderboard_rank gauge
+wakatime_leaderboard_rank 42
+`,
+		},
+		{
+			collector: allTimeCollector,
+			metrics:   []string{"wakatime_cumulative_seconds_total"},
+			want: `
+# HELP wakatime_cumulative_seconds_total Total seconds (all time).
+# TYPE wakatime_cumulative_seconds_total counter
+wakatime_cumulative_seconds_total 1.234567e+06
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.collector, func(t *testing.T) {
+			c, err := NewWakaCollector(newTestInputs(t, testAPIKey), log.NewNopLogger(), tt.collector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(c)
+
+			want := tt.want + `
+# HELP wakatime_up wakatime_exporter: Whether all requests to Wakatime during the scrape succeeded.
+# TYPE wakatime_up gauge
+wakatime_up 1
+# HELP wakatime_scrape_collector_success wakatime_exporter: Whether a collector succeeded.
+# TYPE wakatime_scrape_collector_success gauge
+wakatime_scrape_collector_success{collector="` + tt.collector + `"} 1
+`
+			metrics := append(tt.metrics, "wakatime_up", "wakatime_scrape_collector_success")
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(want), metrics...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCollectorsUnauthorized(t *testing.T) {
+	c, err := NewWakaCollector(newTestInputs(t, "wrong"), log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := map[string]float64{}
+	for name := range c.Collectors {
+		before[name] = testutil.ToFloat64(scrapeErrorsTotal.WithLabelValues(name, ReasonUnauthorized))
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	want := `
+# HELP wakatime_up wakatime_exporter: Whether all requests to Wakatime during the scrape succeeded.
+# TYPE wakatime_up gauge
+wakatime_up 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "wakatime_up"); err != nil {
+		t.Error(err)
+	}
+
+	for name := range c.Collectors {
+		if got := testutil.ToFloat64(scrapeErrorsTotal.WithLabelValues(name, ReasonUnauthorized)) - before[name]; got != 1 {
+			t.Errorf("collector %s: got %v unauthorized errors, want 1", name, got)
+		}
+	}
+}
+
+func TestStatuses(t *testing.T) {
+	status := func(apiKey string) CollectorStatus {
+		in := newTestInputs(t, apiKey)
+		in.Credential = "status-test"
+		c, err := NewWakaCollector(in, log.NewNopLogger(), leaderCollectorName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(c)
+		if _, err := testutil.GatherAndCount(reg); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range Statuses() {
+			if s.Credential == "status-test" && s.URI == in.URI.String() && s.Collector == leaderCollectorName {
+				return s
+			}
+		}
+		t.Fatal("no status recorded")
+		return CollectorStatus{}
+	}
+
+	s := status("wrong")
+	if s.Reason != ReasonUnauthorized || s.Err == "" || !s.LastSuccess.IsZero() || s.LastRun.IsZero() {
+		t.Errorf("unexpected status after a failed run: %+v", s)
+	}
+	s = status(testAPIKey)
+	if s.Reason != "" || s.Err != "" || s.LastSuccess != s.LastRun {
+		t.Errorf("unexpected status after a successful run: %+v", s)
+	}
+}
+
+// TestCollectorsStrictDecode checks that the fake API matches the schema
+// expected by the collectors.
+func TestCollectorsStrictDecode(t *testing.T) {
+	in := newTestInputs(t, testAPIKey)
+	in.StrictDecode = true
+	c, err := NewWakaCollector(in, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints := []string{
+		wakatime.AllTimeSinceTodayEndpoint,
+		wakatime.GoalsEndpoint,
+		wakatime.LeadersEndpoint,
+		wakatime.SummariesEndpoint,
+	}
+	before := map[string]map[schemaDriftKey]float64{}
+	for _, e := range endpoints {
+		before[e] = schemaDrift(t, e)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	n, err := testutil.GatherAndCount(reg, "wakatime_scrape_collector_success")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(c.Collectors) {
+		t.Fatalf("got %d collector results, want %d", n, len(c.Collectors))
+	}
+	for _, e := range endpoints {
+		for k, v := range schemaDrift(t, e) {
+			if v != before[e][k] {
+				t.Errorf("%s: got %s schema drift for field %s", e, k.kind, k.field)
+			}
+		}
+	}
+}
+
+func TestPruneStatuses(t *testing.T) {
+	statusMtx.Lock()
+	defer statusMtx.Unlock()
+	saved, savedMaxAge := statuses, statusMaxAge
+	defer func() { statuses, statusMaxAge = saved, savedMaxAge }()
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses = make(map[statusKey]*CollectorStatus)
+	for i := 0; i < maxStatuses; i++ {
+		statuses[statusKey{uri: fmt.Sprint(i)}] = &CollectorStatus{LastRun: now.Add(time.Duration(i) * time.Second)}
+	}
+	statusMaxAge = 0
+	pruneStatuses(now.Add(time.Hour))
+	if len(statuses) != maxStatuses-1 {
+		t.Errorf("got %d statuses, want %d", len(statuses), maxStatuses-1)
+	}
+	if _, ok := statuses[statusKey{uri: "0"}]; ok {
+		t.Error("the least recently run status was not dropped")
+	}
+
+	statusMaxAge = time.Minute
+	pruneStatuses(now.Add(maxStatuses * time.Second))
+	if len(statuses) != 60 {
+		t.Errorf("got %d statuses, want the 60 run in the last minute", len(statuses))
+	}
+}
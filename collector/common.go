@@ -16,10 +16,11 @@ limitations under the License.
 package collector
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -29,6 +30,8 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
 )
 
 // Namespace defines the common namespace to be used by all metrics.
@@ -42,56 +45,203 @@ func BoolToBinary(b bool) string {
 	return "0"
 }
 
+// FetchFunc fetches subPath below uri with the given query parameters and
+// returns the response body, which the caller must close. The request is
+// abandoned when ctx is done.
+type FetchFunc func(ctx context.Context, uri url.URL, subPath string, params url.Values) (io.ReadCloser, error)
+
 // FetchHTTP is a generic fetch method for Wakatime API endpoints
-func FetchHTTP(token string, sslVerify bool, timeout time.Duration, logger log.Logger) func(uri url.URL, subPath string, params url.Values) (io.ReadCloser, error) {
-	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: !sslVerify}}
-	client := http.Client{
-		Timeout:   timeout,
+func FetchHTTP(in CommonInputs, logger log.Logger) FetchFunc {
+	if in.ReplayDir != "" {
+		return replayFetch(in.ReplayDir, logger)
+	}
+
+	tr := in.Transport
+	if tr == nil {
+		tr = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: !in.SSLVerify}}
+	}
+	client := &http.Client{
 		Transport: tr,
 	}
-	sEnc := base64.StdEncoding.EncodeToString([]byte(token))
-	return func(uri url.URL, subPath string, params url.Values) (io.ReadCloser, error) {
+	auth := in.Auth
+	if auth == nil {
+		auth = BasicAuth{APIKey: in.Token}
+	}
+	fetch := func(ctx context.Context, uri url.URL, subPath string, params url.Values) (io.ReadCloser, error) {
 		uri.Path = path.Join(uri.Path, subPath)
 		uri.RawQuery = params.Encode()
 		url := uri.String()
 
 		level.Info(logger).Log("msg", "Scraping Wakatime", "path", subPath, "url", url)
 
-		req, err := http.NewRequest(http.MethodGet, url, nil)
+		// The timeout covers every attempt, so that retries cannot hold up
+		// the scrape for longer than a single request could.
+		var cancel context.CancelFunc
+		if in.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, in.Timeout)
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+
+		resp, err := in.Retry.do(ctx, func(ctx context.Context) (*http.Response, error) {
+			if in.RateLimiter != nil {
+				if err := in.RateLimiter.Wait(ctx); err != nil {
+					return nil, err
+				}
+			}
+
+			for reauthorized := false; ; reauthorized = true {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+				if err != nil {
+					return nil, err
+				}
+				if err := auth.Authorize(ctx, req); err != nil {
+					return nil, err
+				}
+				if in.Cache != nil {
+					in.Cache.setConditional(url, req)
+				}
+
+				begin := time.Now()
+				resp, err := client.Do(req)
+				observeRequest(subPath, resp, err, time.Since(begin))
+				if err != nil {
+					return nil, err
+				}
+				observeRateLimitHeaders(in.Credential, resp.Header, time.Now())
+
+				// Credentials which can be renewed are tried again once
+				// after Wakatime rejects them, e.g. a revoked OAuth token.
+				if inv, ok := auth.(Invalidator); ok && resp.StatusCode == http.StatusUnauthorized && !reauthorized && inv.Invalidate(req) {
+					level.Info(logger).Log("msg", "Wakatime rejected the credentials, renewing them", "path", subPath)
+					resp.Body.Close()
+					continue
+				}
+				return resp, nil
+			}
+		}, subPath, logger)
 		if err != nil {
-			return nil, err
+			cancel()
+			return nil, requestError(err)
 		}
 
-		req.Header = map[string][]string{
-			"Authorization": {"Basic " + sEnc},
+		if in.MaxBodySize > 0 {
+			resp.Body = &limitedBody{ReadCloser: resp.Body, limit: in.MaxBodySize, remaining: in.MaxBodySize}
 		}
 
-		resp, err := client.Do(req)
+		if in.Cache == nil {
+			return &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}, nil
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			cancel()
+			body, ok := in.Cache.notModified(url)
+			if !ok {
+				return nil, statusError(resp.StatusCode)
+			}
+			level.Debug(logger).Log("msg", "Wakatime response not modified", "path", subPath)
+			notModifiedTotal.WithLabelValues(subPath).Inc()
+			return body, nil
+		}
+		body, err := in.Cache.storeValidated(url, resp)
 		if err != nil {
+			cancel()
 			return nil, err
 		}
+		return &cancelOnClose{ReadCloser: body, cancel: cancel}, nil
+	}
+	if in.RecordDir != "" {
+		return recordFetch(in.RecordDir, fetch, logger)
+	}
+	return fetch
+}
 
-		if !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
-			resp.Body.Close()
-			return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+// cancelOnClose releases the request context once the response body has been
+// consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// limitedBody fails reads once more than limit bytes have been read from the
+// underlying body.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+	// Read one byte past the limit, so that a body of exactly limit bytes is
+	// not mistaken for a larger one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, b.tooLarge()
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) tooLarge() error {
+	return &UpstreamError{Reason: ReasonBodyTooLarge, Err: &BodyTooLargeError{Limit: b.limit}}
+}
+
+// newClient returns a Wakatime API client for the user described by in, which
+// sends requests via fetch.
+func newClient(in CommonInputs, fetch FetchFunc, logger log.Logger) *wakatime.Client {
+	return &wakatime.Client{
+		BaseURI: in.BaseURI,
+		UserURI: in.URI,
+		Fetch:   wakatime.Fetcher(fetch),
+		Decode:  newDecoder(in.StrictDecode, logger),
+	}
+}
+
+// newDecoder returns a decoder for Wakatime responses. If strict is set, each
+// response is also checked for fields which do not match the expected schema.
+func newDecoder(strict bool, logger log.Logger) wakatime.Decoder {
+	return func(endpoint string, body io.ReadCloser, v interface{}) error {
+		if !strict {
+			return ReadAndUnmarshal(body, v)
 		}
-		return resp.Body, nil
+		defer body.Close()
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return requestError(err)
+		}
+		checkSchema(endpoint, data, v, logger)
+		return ReadAndUnmarshal(ioutil.NopCloser(bytes.NewReader(data)), v)
 	}
 }
 
-// ReadAndUnmarshal reads the JSON response body and unmarshals the response
+// ReadAndUnmarshal decodes the JSON response body into object as it is read,
+// and closes the body.
 func ReadAndUnmarshal(body io.ReadCloser, object interface{}) error {
-	respBody, readErr := ioutil.ReadAll(body)
+	defer body.Close()
 
-	if readErr != nil {
-		return readErr
+	err := json.NewDecoder(body).Decode(object)
+	if err == nil {
+		return nil
 	}
 
-	var jsonErr error
-	jsonErr = json.Unmarshal(respBody, &object)
-	if jsonErr != nil {
-		return jsonErr
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &UpstreamError{Reason: ReasonDecodeError, Err: err}
 	}
-
-	return nil
+	return requestError(err)
 }
@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLimitedBody(t *testing.T) {
+	const limit = 16
+	readers := map[string]func(io.Reader) io.Reader{
+		"whole":    func(r io.Reader) io.Reader { return r },
+		"one byte": iotest.OneByteReader,
+	}
+	for _, size := range []int{0, limit - 1, limit, limit + 1, 4 * limit} {
+		for name, wrap := range readers {
+			t.Run(fmt.Sprintf("%d bytes/%s", size, name), func(t *testing.T) {
+				body := strings.Repeat("x", size)
+				b := &limitedBody{
+					ReadCloser: ioutil.NopCloser(wrap(strings.NewReader(body))),
+					limit:      limit,
+					remaining:  limit,
+				}
+				data, err := ioutil.ReadAll(b)
+
+				if size <= limit {
+					if err != nil {
+						t.Errorf("got error %v", err)
+					}
+					if string(data) != body {
+						t.Errorf("got %d bytes, want %d", len(data), size)
+					}
+					return
+				}
+
+				var upstreamErr *UpstreamError
+				var tooLarge *BodyTooLargeError
+				if !errors.As(err, &upstreamErr) || upstreamErr.Reason != ReasonBodyTooLarge ||
+					!errors.As(err, &tooLarge) || tooLarge.Limit != limit {
+					t.Errorf("got error %v, want the body to be too large", err)
+				}
+				if len(data) != limit {
+					t.Errorf("got %d bytes, want %d", len(data), limit)
+				}
+				if _, err := b.Read(make([]byte, 1)); !errors.As(err, &tooLarge) {
+					t.Errorf("got error %v reading again, want the body to be too large", err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var notModifiedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "not_modified_total",
+		Help:      "wakatime_exporter: Number of conditional requests for which Wakatime reported no changes.",
+	},
+	[]string{"endpoint"},
+)
+
+func init() {
+	registerExporterMetrics(notModifiedTotal)
+}
+
+// maxValidatedEntries is the number of responses kept for conditional
+// requests by each ResponseCache. Once it is reached, the least recently
+// stored response is dropped, so that probes of many targets cannot grow the
+// cache without bound.
+const maxValidatedEntries = 256
+
+// validatedEntry is a response which can be revalidated with a conditional
+// request.
+type validatedEntry struct {
+	body         []byte
+	etag         string
+	lastModified string
+	stored       time.Time
+}
+
+// setConditional adds the validators of the last response for key to req, if
+// there is one.
+func (c *ResponseCache) setConditional(key string, req *http.Request) {
+	c.mtx.Lock()
+	e, ok := c.validated[key]
+	c.mtx.Unlock()
+	if !ok {
+		return
+	}
+	if e.etag != "" {
+		req.Header.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		req.Header.Set("If-Modified-Since", e.lastModified)
+	}
+}
+
+// notModified returns the body of the last response for key, to be used in
+// place of a 304 Not Modified response.
+func (c *ResponseCache) notModified(key string) (io.ReadCloser, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.validated[key]
+	if !ok {
+		return nil, false
+	}
+	return ioutil.NopCloser(bytes.NewReader(e.body)), true
+}
+
+// storeValidated reads resp and, if it carries validators, remembers it so
+// that the next request for key can be made conditional. The returned body
+// replaces resp.Body, which is closed.
+func (c *ResponseCache) storeValidated(key string, resp *http.Response) (io.ReadCloser, error) {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, requestError(err)
+	}
+
+	c.addValidated(key, validatedEntry{body: data, etag: etag, lastModified: lastModified, stored: time.Now()})
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// addValidated stores e under key, dropping the least recently stored entry
+// if the cache is full.
+func (c *ResponseCache) addValidated(key string, e validatedEntry) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.validated[key]; !ok && len(c.validated) >= maxValidatedEntries {
+		var oldest string
+		for k, v := range c.validated {
+			if oldest == "" || v.stored.Before(c.validated[oldest].stored) {
+				oldest = k
+			}
+		}
+		delete(c.validated, oldest)
+	}
+	c.validated[key] = e
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"net/url"
+	"path"
+)
+
+// endpoint is a Wakatime API endpoint queried by a collector.
+type endpoint struct {
+	path string
+	// user is set if path is relative to the URI of the user, rather than to
+	// the base URI.
+	user bool
+}
+
+// collectorEndpoints holds the endpoints queried by each collector.
+var collectorEndpoints = make(map[string][]endpoint)
+
+// registerUserEndpoint records that collector queries the endpoint at path
+// relative to the URI of the user.
+func registerUserEndpoint(collector, path string) {
+	collectorEndpoints[collector] = append(collectorEndpoints[collector], endpoint{path: path, user: true})
+}
+
+// registerBaseEndpoint records that collector queries the endpoint at path
+// relative to the base URI.
+func registerBaseEndpoint(collector, path string) {
+	collectorEndpoints[collector] = append(collectorEndpoints[collector], endpoint{path: path})
+}
+
+// ownerOnly returns whether the named collector queries an endpoint which is
+// not relative to the URI of the user, and so always reports on the user
+// owning the credentials.
+func ownerOnly(collector string) bool {
+	for _, e := range collectorEndpoints[collector] {
+		if !e.user {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoints returns the URIs of the endpoints which the named collector
+// queries using in, without any query parameters.
+func (in CommonInputs) Endpoints(collector string) []url.URL {
+	uris := make([]url.URL, 0, len(collectorEndpoints[collector]))
+	for _, e := range collectorEndpoints[collector] {
+		uri := in.BaseURI
+		if e.user {
+			uri = in.URI
+		}
+		uri.Path = path.Join(uri.Path, e.path)
+		uri.RawQuery = ""
+		uris = append(uris, uri)
+	}
+	return uris
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Reasons for which a request to Wakatime can fail, used as the reason label
+// of wakatime_scrape_errors_total.
+const (
+	ReasonUnauthorized = "unauthorized"
+	ReasonForbidden    = "forbidden"
+	ReasonNotFound     = "not_found"
+	ReasonRateLimited  = "rate_limited"
+	ReasonClientError  = "client_error"
+	ReasonServerError  = "server_error"
+	ReasonDecodeError  = "decode_error"
+	ReasonBodyTooLarge = "body_too_large"
+	ReasonTimeout      = "timeout"
+	ReasonNetworkError = "network_error"
+	// ReasonOther is used for collector errors which did not come from Wakatime.
+	ReasonOther = "other"
+)
+
+// UpstreamError is returned by the fetch layer when a request to Wakatime
+// fails.
+type UpstreamError struct {
+	Reason string
+	// StatusCode is the HTTP status returned by Wakatime, or zero if no
+	// response was received.
+	StatusCode int
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Err)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// BodyTooLargeError is the cause of the UpstreamError returned when a
+// response body exceeds the configured maximum size.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
+
+// statusError returns the UpstreamError for an unsuccessful HTTP status.
+func statusError(code int) *UpstreamError {
+	reason := ReasonClientError
+	switch {
+	case code == http.StatusUnauthorized:
+		reason = ReasonUnauthorized
+	case code == http.StatusForbidden:
+		reason = ReasonForbidden
+	case code == http.StatusNotFound:
+		reason = ReasonNotFound
+	case code == http.StatusTooManyRequests:
+		reason = ReasonRateLimited
+	case code >= 500:
+		reason = ReasonServerError
+	}
+	return &UpstreamError{Reason: reason, StatusCode: code, Err: fmt.Errorf("HTTP status %d", code)}
+}
+
+// requestError wraps an error which prevented a response from being
+// received, unless it already is an UpstreamError.
+func requestError(err error) error {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return err
+	}
+
+	var netErr net.Error
+	reason := ReasonNetworkError
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		reason = ReasonRateLimited
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		reason = ReasonTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		reason = ReasonTimeout
+	}
+	return &UpstreamError{Reason: reason, Err: err}
+}
+
+// errorReason returns the reason label for an error returned by a collector.
+func errorReason(err error) string {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.Reason
+	}
+	return ReasonOther
+}
+
+// isUpstreamError reports whether err was caused by a failed request to
+// Wakatime.
+func isUpstreamError(err error) bool {
+	var upstreamErr *UpstreamError
+	return errors.As(err, &upstreamErr)
+}
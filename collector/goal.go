@@ -16,35 +16,58 @@ limitations under the License.
 package collector
 
 import (
-	"io"
-	"net/url"
+	"context"
 	"strconv"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
 )
 
 const (
 	goalCollectorName = "goal"
 	goalSubsystem     = "goal"
-	goalEndpoint      = "goals"
 )
 
 type goalCollector struct {
 	goalThreshold *prometheus.Desc
 	goalProgress  *prometheus.Desc
-	uri           url.URL
-	fetchStat     func(url.URL, string, url.Values) (io.ReadCloser, error)
+	maxPages      int
+	client        *wakatime.Client
 	logger        log.Logger
 }
 
+var (
+	goalCacheTTL = collectorFlag(goalCollectorName, "cache-ttl",
+		"How long to serve goals from cache before querying Wakatime again (0 disables caching).",
+	).Default("0s").Duration()
+	goalMaxPages = collectorFlag(goalCollectorName, "max-pages",
+		"Maximum number of pages of goals to fetch (0 fetches all pages).",
+	).Default("10").Int()
+	goalPollInterval = collectorFlag(goalCollectorName, "poll-interval",
+		"How often to query goals in background polling mode.",
+	).Default("10m").Duration()
+)
+
 func init() {
 	registerCollector(goalCollectorName, defaultEnabled, NewGoalCollector)
+	registerPollInterval(goalCollectorName, goalPollInterval)
+	registerUserEndpoint(goalCollectorName, wakatime.GoalsEndpoint)
 }
 
 // NewGoalCollector returns a new Collector exposing all-time stats.
 func NewGoalCollector(in CommonInputs, logger log.Logger) (Collector, error) {
+	cacheTTL, err := in.durationOption(goalCollectorName, "cache-ttl", *goalCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	maxPages, err := in.intOption(goalCollectorName, "max-pages", *goalMaxPages)
+	if err != nil {
+		return nil, err
+	}
+
 	return &goalCollector{
 		goalThreshold: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, goalSubsystem, "threshold_seconds"),
@@ -64,28 +87,28 @@ func NewGoalCollector(in CommonInputs, logger log.Logger) (Collector, error) {
 			},
 			nil,
 		),
-		uri:       in.URI,
-		fetchStat: FetchHTTP(in.Token, in.SSLVerify, in.Timeout, logger),
-		logger:    logger,
+		maxPages: maxPages,
+		client:   newClient(in, cachedFetch(in.Cache, cacheTTL, FetchHTTP(in, logger)), logger),
+		logger:   logger,
 	}, nil
 }
 
-func (c *goalCollector) Update(ch chan<- prometheus.Metric) error {
-	params := url.Values{}
-	params.Add("cache", "false")
-
-	body, fetchErr := c.fetchStat(c.uri, goalEndpoint, params)
-	if fetchErr != nil {
-		return fetchErr
-	}
-
-	goalStats := wakatimeGoal{}
-	if err := ReadAndUnmarshal(body, &goalStats); err != nil {
+func (c *goalCollector) Update(ctx context.Context, ch chan<- prometheus.Metric) error {
+	goalStats := wakatime.Goals{}
+	err := fetchPages(wakatime.GoalsEndpoint, c.maxPages, c.logger, func(page int) (int, error) {
+		goals, err := c.client.Goals(ctx, wakatime.GoalsOptions{Page: page, NoCache: true})
+		if err != nil {
+			return 0, err
+		}
+		goalStats.Data = append(goalStats.Data, goals.Data...)
+		goalStats.Total = goals.Total
+		goalStats.TotalPages = goals.TotalPages
+		return goals.TotalPages, nil
+	})
+	if err != nil {
 		return err
 	}
 
-	defer body.Close()
-
 	level.Info(c.logger).Log(
 		"msg", "Collecting goals from Wakatime",
 		"total", goalStats.Total,
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "request_duration_seconds",
+			Help:      "wakatime_exporter: Latency of requests to the Wakatime API.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"endpoint"},
+	)
+	responsesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "responses_total",
+			Help:      "wakatime_exporter: Number of requests to the Wakatime API, by HTTP status code or error class.",
+		},
+		[]string{"endpoint", "code"},
+	)
+)
+
+func init() {
+	registerExporterMetrics(requestDurationSeconds, responsesTotal)
+}
+
+// observeRequest records the outcome of a single request to the Wakatime API.
+func observeRequest(endpoint string, resp *http.Response, err error, duration time.Duration) {
+	requestDurationSeconds.WithLabelValues(endpoint).Observe(duration.Seconds())
+
+	code := errorClass(err)
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	responsesTotal.WithLabelValues(endpoint, code).Inc()
+}
+
+// errorClass returns a short description of a request error, suitable for use
+// as a label value.
+func errorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "error"
+	}
+}
@@ -16,61 +16,67 @@ limitations under the License.
 package collector
 
 import (
-	"io"
-	"net/url"
+	"context"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
 )
 
 const (
 	leaderCollectorName = "leader"
 	leaderSubsystem     = "leaderboard"
-	leaderEndpoint      = "leaders"
 )
 
 type leaderCollector struct {
-	rank      *prometheus.Desc
-	uri       url.URL
-	fetchStat func(url.URL, string, url.Values) (io.ReadCloser, error)
-	logger    log.Logger
+	rank   *prometheus.Desc
+	client *wakatime.Client
+	logger log.Logger
 }
 
+var (
+	leaderCacheTTL = collectorFlag(leaderCollectorName, "cache-ttl",
+		"How long to serve the leaderboard from cache before querying Wakatime again (0 disables caching).",
+	).Default("0s").Duration()
+	leaderPollInterval = collectorFlag(leaderCollectorName, "poll-interval",
+		"How often to query the leaderboard in background polling mode.",
+	).Default("1h").Duration()
+)
+
 func init() {
 	registerCollector(leaderCollectorName, defaultEnabled, NewLeaderCollector)
+	registerPollInterval(leaderCollectorName, leaderPollInterval)
+	registerBaseEndpoint(leaderCollectorName, wakatime.LeadersEndpoint)
 }
 
 // NewLeaderCollector returns a new Collector exposing all-time stats.
 func NewLeaderCollector(in CommonInputs, logger log.Logger) (Collector, error) {
+	cacheTTL, err := in.durationOption(leaderCollectorName, "cache-ttl", *leaderCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	return &leaderCollector{
 		rank: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, leaderSubsystem, "rank"),
 			"Current rank of the user.",
 			nil, nil,
 		),
-		uri:       in.BaseURI,
-		fetchStat: FetchHTTP(in.Token, in.SSLVerify, in.Timeout, logger),
-		logger:    logger,
+		client: newClient(in, cachedFetch(in.Cache, cacheTTL, FetchHTTP(in, logger)), logger),
+		logger: logger,
 	}, nil
 }
 
-func (c *leaderCollector) Update(ch chan<- prometheus.Metric) error {
-	params := url.Values{}
-	params.Add("cache", "false")
-
-	body, fetchErr := c.fetchStat(c.uri, leaderEndpoint, params)
-	if fetchErr != nil {
-		return fetchErr
-	}
-
-	leaderStats := wakatimeLeader{}
-	if err := ReadAndUnmarshal(body, &leaderStats); err != nil {
+func (c *leaderCollector) Update(ctx context.Context, ch chan<- prometheus.Metric) error {
+	// The current user's rank is included in every page, so only the first
+	// page is fetched.
+	leaderStats, err := c.client.Leaders(ctx, wakatime.LeadersOptions{NoCache: true})
+	if err != nil {
 		return err
 	}
 
-	defer body.Close()
-
 	level.Info(c.logger).Log(
 		"msg", "Collecting rank from Wakatime",
 		"page", leaderStats.Page,
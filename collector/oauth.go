@@ -0,0 +1,266 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// oauthExpiryDelta is how long before its expiry an access token is refreshed.
+const oauthExpiryDelta = time.Minute
+
+var oauthTokenExpiry = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "oauth_token_expiry_timestamp_seconds",
+		Help:      "wakatime_exporter: Time at which the current OAuth2 access token expires.",
+	},
+)
+
+func init() {
+	registerExporterMetrics(oauthTokenExpiry)
+}
+
+// OAuthConfig configures authentication using OAuth2 access tokens.
+type OAuthConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	// TokenFile holds the current token as JSON. It is read on startup and
+	// rewritten whenever the token is refreshed.
+	TokenFile string
+}
+
+type oauthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// OAuth authorizes requests with an OAuth2 bearer token, which is refreshed
+// via the token endpoint when it expires. It is safe for concurrent use.
+type OAuth struct {
+	cfg    OAuthConfig
+	client *http.Client
+	logger log.Logger
+
+	mtx   sync.Mutex
+	token oauthToken
+}
+
+// NewOAuth returns an OAuth authorizer using the token stored in
+// cfg.TokenFile. Token refreshes are sent using transport, or the default
+// transport if nil.
+func NewOAuth(cfg OAuthConfig, transport http.RoundTripper, logger log.Logger) (*OAuth, error) {
+	data, err := ioutil.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read token file: %s", err)
+	}
+	var token oauthToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("couldn't parse token file %s: %s", cfg.TokenFile, err)
+	}
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		return nil, fmt.Errorf("token file %s contains neither an access token nor a refresh token", cfg.TokenFile)
+	}
+	if !token.Expiry.IsZero() {
+		oauthTokenExpiry.Set(float64(token.Expiry.Unix()))
+	}
+
+	return &OAuth{
+		cfg:    cfg,
+		client: &http.Client{Transport: transport},
+		logger: logger,
+		token:  token,
+	}, nil
+}
+
+// Authorize implements the Authorizer interface.
+func (a *OAuth) Authorize(ctx context.Context, req *http.Request) error {
+	token, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate implements the Invalidator interface. The access token used by
+// req is refreshed before the next request, if a refresh token is available.
+func (a *OAuth) Invalidate(req *http.Request) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if a.token.RefreshToken == "" {
+		return false
+	}
+	// The token may already have been refreshed by a concurrent request.
+	if req.Header.Get("Authorization") == "Bearer "+a.token.AccessToken {
+		a.token.AccessToken = ""
+	}
+	return true
+}
+
+func (a *OAuth) accessToken(ctx context.Context) (string, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.token.AccessToken != "" && (a.token.Expiry.IsZero() || time.Now().Add(oauthExpiryDelta).Before(a.token.Expiry)) {
+		return a.token.AccessToken, nil
+	}
+	if err := a.refresh(ctx); err != nil {
+		return "", fmt.Errorf("couldn't refresh OAuth token: %s", err)
+	}
+	return a.token.AccessToken, nil
+}
+
+// refresh exchanges the refresh token for a new access token and persists
+// the result. a.mtx must be held.
+func (a *OAuth) refresh(ctx context.Context) error {
+	if a.token.RefreshToken == "" {
+		return fmt.Errorf("access token expired and no refresh token is available")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", a.token.RefreshToken)
+	form.Set("client_id", a.cfg.ClientID)
+	form.Set("client_secret", a.cfg.ClientSecret)
+	if a.cfg.RedirectURI != "" {
+		form.Set("redirect_uri", a.cfg.RedirectURI)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	level.Info(a.logger).Log("msg", "Refreshing OAuth token", "url", a.cfg.TokenURL)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		return fmt.Errorf("token endpoint returned HTTP status %d", resp.StatusCode)
+	}
+
+	token, err := parseTokenResponse(resp.Header.Get("Content-Type"), body, time.Now())
+	if err != nil {
+		return err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = a.token.RefreshToken
+	}
+	a.token = token
+
+	if !token.Expiry.IsZero() {
+		oauthTokenExpiry.Set(float64(token.Expiry.Unix()))
+	}
+	if err := a.persist(); err != nil {
+		level.Warn(a.logger).Log("msg", "Couldn't persist refreshed OAuth token", "file", a.cfg.TokenFile, "err", err)
+	}
+	return nil
+}
+
+// persist atomically replaces the token file with the current token.
+func (a *OAuth) persist() error {
+	data, err := json.MarshalIndent(a.token, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(a.cfg.TokenFile), ".wakatime-token")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), a.cfg.TokenFile)
+}
+
+// parseTokenResponse parses a token endpoint response, which Wakatime sends
+// either as JSON or form encoded.
+func parseTokenResponse(contentType string, body []byte, now time.Time) (oauthToken, error) {
+	var (
+		token     oauthToken
+		expiresIn string
+	)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" {
+		var resp struct {
+			AccessToken  string      `json:"access_token"`
+			RefreshToken string      `json:"refresh_token"`
+			ExpiresIn    json.Number `json:"expires_in"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return token, fmt.Errorf("couldn't parse token response: %s", err)
+		}
+		token.AccessToken = resp.AccessToken
+		token.RefreshToken = resp.RefreshToken
+		expiresIn = resp.ExpiresIn.String()
+	} else {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return token, fmt.Errorf("couldn't parse token response: %s", err)
+		}
+		token.AccessToken = values.Get("access_token")
+		token.RefreshToken = values.Get("refresh_token")
+		expiresIn = values.Get("expires_in")
+	}
+
+	if token.AccessToken == "" {
+		return token, fmt.Errorf("token response did not contain an access token")
+	}
+	if seconds, err := strconv.ParseFloat(expiresIn, 64); err == nil && seconds > 0 {
+		token.Expiry = now.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return token, nil
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTokenServer returns a token endpoint which exchanges the refresh token
+// oldRefresh for the access token "new-access" and the refresh token
+// "new-refresh", using the given content type. calls counts the requests.
+func newTokenServer(t *testing.T, contentType, oldRefresh string, calls *int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != oldRefresh ||
+			r.PostForm.Get("client_id") != "id" || r.PostForm.Get("client_secret") != "secret" {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		if contentType == "application/json" {
+			fmt.Fprint(w, `{"access_token": "new-access", "refresh_token": "new-refresh", "expires_in": 3600}`)
+		} else {
+			fmt.Fprint(w, "access_token=new-access&refresh_token=new-refresh&expires_in=3600")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// writeToken writes token to a token file in dir.
+func writeToken(t *testing.T, dir string, token oauthToken) string {
+	data, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "token.json")
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// authorize returns the Authorization header set by a.
+func authorize(t *testing.T, a *OAuth) string {
+	req, err := http.NewRequest(http.MethodGet, "http://wakatime.invalid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authorize(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	return req.Header.Get("Authorization")
+}
+
+func TestOAuthRefresh(t *testing.T) {
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		t.Run(contentType, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "oauth")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			var calls int
+			srv := newTokenServer(t, contentType, "old-refresh", &calls)
+			file := writeToken(t, dir, oauthToken{
+				AccessToken:  "old-access",
+				RefreshToken: "old-refresh",
+				Expiry:       time.Now().Add(-time.Hour),
+			})
+			a, err := NewOAuth(OAuthConfig{
+				TokenURL:     srv.URL,
+				ClientID:     "id",
+				ClientSecret: "secret",
+				TokenFile:    file,
+			}, nil, log.NewNopLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			for i := 0; i < 2; i++ {
+				if got := authorize(t, a); got != "Bearer new-access" {
+					t.Errorf("got Authorization %q, want the refreshed token", got)
+				}
+			}
+			if calls != 1 {
+				t.Errorf("got %d token requests, want 1", calls)
+			}
+
+			expiry := testutil.ToFloat64(oauthTokenExpiry)
+			if min, max := start.Add(time.Hour).Unix(), time.Now().Add(time.Hour).Unix(); expiry < float64(min) || expiry > float64(max) {
+				t.Errorf("got expiry %v, want between %d and %d", expiry, min, max)
+			}
+
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var persisted oauthToken
+			if err := json.Unmarshal(data, &persisted); err != nil {
+				t.Fatal(err)
+			}
+			if persisted.AccessToken != "new-access" || persisted.RefreshToken != "new-refresh" || persisted.Expiry.Unix() != int64(expiry) {
+				t.Errorf("unexpected persisted token: %+v", persisted)
+			}
+
+			// A new authorizer starts from the rotated refresh token.
+			persisted.Expiry = time.Now().Add(-time.Hour)
+			writeToken(t, dir, persisted)
+			srv = newTokenServer(t, contentType, "new-refresh", &calls)
+			a, err = NewOAuth(OAuthConfig{
+				TokenURL:     srv.URL,
+				ClientID:     "id",
+				ClientSecret: "secret",
+				TokenFile:    file,
+			}, nil, log.NewNopLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := authorize(t, a); got != "Bearer new-access" {
+				t.Errorf("got Authorization %q after restart, want the refreshed token", got)
+			}
+		})
+	}
+}
+
+func TestOAuthValidToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var calls int
+	srv := newTokenServer(t, "application/json", "old-refresh", &calls)
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	file := writeToken(t, dir, oauthToken{
+		AccessToken:  "old-access",
+		RefreshToken: "old-refresh",
+		Expiry:       expiry,
+	})
+	a, err := NewOAuth(OAuthConfig{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret", TokenFile: file}, nil, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := authorize(t, a); got != "Bearer old-access" {
+		t.Errorf("got Authorization %q, want the stored token", got)
+	}
+	if calls != 0 {
+		t.Errorf("got %d token requests, want 0", calls)
+	}
+	if got := testutil.ToFloat64(oauthTokenExpiry); got != float64(expiry.Unix()) {
+		t.Errorf("got expiry %v, want %d", got, expiry.Unix())
+	}
+}
+
+func TestParseTokenResponse(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		contentType string
+		body        string
+		want        oauthToken
+		wantErr     bool
+	}{
+		{
+			contentType: "application/json; charset=utf-8",
+			body:        `{"access_token": "a", "refresh_token": "r", "expires_in": "60"}`,
+			want:        oauthToken{AccessToken: "a", RefreshToken: "r", Expiry: now.Add(time.Minute)},
+		},
+		{
+			contentType: "application/x-www-form-urlencoded",
+			body:        "access_token=a&expires_in=60",
+			want:        oauthToken{AccessToken: "a", Expiry: now.Add(time.Minute)},
+		},
+		{
+			contentType: "text/plain",
+			body:        "access_token=a",
+			want:        oauthToken{AccessToken: "a"},
+		},
+		{contentType: "application/json", body: `{"refresh_token": "r"}`, wantErr: true},
+		{contentType: "application/json", body: `access_token=a`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTokenResponse(tt.contentType, []byte(tt.body), now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s %s: got error %v", tt.contentType, tt.body, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s %s: got %+v, want %+v", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestOAuthRejected(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		refreshToken string
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "refreshed", refreshToken: "old-refresh", wantRequests: 2},
+		{name: "no refresh token", wantRequests: 1, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "oauth")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			var calls, requests int
+			tokenSrv := newTokenServer(t, "application/json", "old-refresh", &calls)
+			// The stored token has no expiry, but has been revoked.
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.Header.Get("Authorization") != "Bearer new-access" {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				fmt.Fprint(w, `{}`)
+			}))
+			defer api.Close()
+
+			file := writeToken(t, dir, oauthToken{AccessToken: "old-access", RefreshToken: tt.refreshToken})
+			a, err := NewOAuth(OAuthConfig{TokenURL: tokenSrv.URL, ClientID: "id", ClientSecret: "secret", TokenFile: file}, nil, log.NewNopLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			uri, err := url.Parse(api.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fetch := FetchHTTP(CommonInputs{Auth: a, Timeout: 5 * time.Second, Retry: RetryPolicy{MaxAttempts: 1}}, log.NewNopLogger())
+
+			body, err := fetch(context.Background(), *uri, "leaders", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if err == nil {
+				body.Close()
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", requests, tt.wantRequests)
+			}
+			if !tt.wantErr && calls != 1 {
+				t.Errorf("got %d token requests, want 1", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// collectorOptions holds the names of the options of each collector, as
+// registered by collectorFlag.
+var collectorOptions = make(map[string]map[string]bool)
+
+// validateOptions checks that options only sets known options of known
+// collectors.
+func validateOptions(options map[string]map[string]string) error {
+	for collector, opts := range options {
+		if _, exist := collectorState[collector]; !exist {
+			return fmt.Errorf("options for missing collector: %s", collector)
+		}
+		for name := range opts {
+			if !collectorOptions[collector][name] {
+				return fmt.Errorf("unknown option for collector %s: %s", collector, name)
+			}
+		}
+	}
+	return nil
+}
+
+// durationOption returns the named option of collector from in, or def, the
+// value of the corresponding flag, if it is not set.
+func (in CommonInputs) durationOption(collector, name string, def time.Duration) (time.Duration, error) {
+	v, ok := in.CollectorOptions[collector][name]
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid option %s for collector %s: %s", name, collector, err)
+	}
+	return d, nil
+}
+
+// intOption returns the named option of collector from in, or def, the value
+// of the corresponding flag, if it is not set.
+func (in CommonInputs) intOption(collector, name string, def int) (int, error) {
+	v, ok := in.CollectorOptions[collector][name]
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid option %s for collector %s: %s", name, collector, err)
+	}
+	return i, nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// fetchPages walks a paged endpoint starting at page 1, calling fetchPage
+// for each page, which returns the total number of pages reported by
+// Wakatime. At most maxPages pages are fetched, or all pages if maxPages is
+// zero.
+func fetchPages(endpoint string, maxPages int, logger log.Logger, fetchPage func(page int) (totalPages int, err error)) error {
+	for page := 1; ; page++ {
+		totalPages, err := fetchPage(page)
+		if err != nil {
+			return err
+		}
+
+		if page >= totalPages {
+			return nil
+		}
+		if maxPages > 0 && page >= maxPages {
+			level.Warn(logger).Log("msg", "Not fetching remaining pages, page limit reached", "path", endpoint, "pages", totalPages, "limit", maxPages)
+			return nil
+		}
+	}
+}
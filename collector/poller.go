@@ -0,0 +1,182 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pollIntervalFlags holds the poll-interval flag of each collector.
+var pollIntervalFlags = make(map[string]*time.Duration)
+
+var scrapeAgeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_age_seconds"),
+	"wakatime_exporter: Time since the collector last ran in background polling mode.",
+	[]string{"collector"},
+	nil,
+)
+
+// registerPollInterval registers the flag holding the default interval at
+// which collector runs in background polling mode.
+func registerPollInterval(collector string, interval *time.Duration) {
+	pollIntervalFlags[collector] = interval
+}
+
+// Poller runs the collectors of a WakaCollector in the background, each on
+// its own interval, and serves their latest results, so that scrapes do not
+// wait for Wakatime. Create instances with NewPoller.
+type Poller struct {
+	collector *WakaCollector
+	logger    log.Logger
+
+	mtx       sync.RWMutex
+	snapshots map[string]snapshot
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// snapshot is the result of the latest run of a collector.
+type snapshot struct {
+	metrics        []prometheus.Metric
+	upstreamFailed bool
+	time           time.Time
+}
+
+// NewPoller returns a Poller for the collectors of c. Call Start to begin
+// polling.
+func NewPoller(c *WakaCollector, logger log.Logger) *Poller {
+	return &Poller{
+		collector: c,
+		logger:    logger,
+		snapshots: make(map[string]snapshot),
+	}
+}
+
+// Start runs every collector immediately, and then again after each of its
+// poll intervals, until Stop is called.
+func (p *Poller) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	for name, c := range p.collector.Collectors {
+		p.wg.Add(1)
+		go p.poll(ctx, name, c, p.collector.pollIntervals[name])
+	}
+}
+
+// Stop stops polling, abandoning any runs in progress, and waits for them to
+// return.
+func (p *Poller) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+func (p *Poller) poll(ctx context.Context, name string, c Collector, interval time.Duration) {
+	defer p.wg.Done()
+	level.Debug(p.logger).Log("msg", "Polling collector in the background", "name", name, "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var err error
+		metrics := bufferMetrics(func(ch chan<- prometheus.Metric) {
+			err = execute(ctx, p.collector.credential, p.collector.uri, name, c, ch, p.logger)
+		})
+		// Keep the previous results if the run was abandoned by Stop.
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.mtx.Lock()
+		p.snapshots[name] = snapshot{metrics: metrics, upstreamFailed: isUpstreamError(err), time: time.Now()}
+		p.mtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (p *Poller) Describe(ch chan<- *prometheus.Desc) {
+	ch <- scrapeDurationDesc
+	ch <- scrapeSuccessDesc
+	ch <- scrapeAgeDesc
+	ch <- upDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (p *Poller) Collect(ch chan<- prometheus.Metric) {
+	p.collect(ch, nil)
+}
+
+// Filtered returns a prometheus.Collector serving the latest results of only
+// the collectors of c, which is usually a filtered version of the
+// WakaCollector being polled.
+func (p *Poller) Filtered(c *WakaCollector) prometheus.Collector {
+	return filteredPoller{Poller: p, collectors: c.Collectors}
+}
+
+// collect sends the latest results of the collectors in only, or of every
+// collector if only is nil. Collectors which have not completed a run yet are
+// left out, as is wakatime_up if none of them have.
+func (p *Poller) collect(ch chan<- prometheus.Metric, only map[string]Collector) {
+	p.mtx.RLock()
+	snapshots := make(map[string]snapshot, len(p.snapshots))
+	for name, s := range p.snapshots {
+		if _, ok := only[name]; ok || only == nil {
+			snapshots[name] = s
+		}
+	}
+	p.mtx.RUnlock()
+
+	now := time.Now()
+	up := 1.0
+	for name, s := range snapshots {
+		for _, m := range s.metrics {
+			ch <- m
+		}
+		ch <- prometheus.MustNewConstMetric(scrapeAgeDesc, prometheus.GaugeValue, now.Sub(s.time).Seconds(), name)
+		if s.upstreamFailed {
+			up = 0
+		}
+	}
+	if len(snapshots) > 0 {
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up)
+	}
+}
+
+// filteredPoller serves the latest results of some of the collectors of a
+// Poller.
+type filteredPoller struct {
+	*Poller
+	collectors map[string]Collector
+}
+
+// Collect implements the prometheus.Collector interface.
+func (p filteredPoller) Collect(ch chan<- prometheus.Metric) {
+	p.collect(ch, p.collectors)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPoller(t *testing.T) {
+	in := newTestInputs(t, testAPIKey)
+	in.CollectorOptions = map[string]map[string]string{
+		leaderCollectorName: {"poll-interval": "1h"},
+	}
+	c, err := NewWakaCollector(in, log.NewNopLogger(), leaderCollectorName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPoller(c, log.NewNopLogger())
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(p)
+
+	// Nothing is served before the first run completes.
+	if n, err := testutil.GatherAndCount(reg); err != nil || n != 0 {
+		t.Fatalf("got %d metrics before polling, err %v", n, err)
+	}
+
+	p.Start()
+	defer p.Stop()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if n, _ := testutil.GatherAndCount(reg, "wakatime_leaderboard_rank"); n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("collector did not run")
+		}
+	}
+
+	want := `
+# HELP wakatime_leaderboard_rank Current rank of the user.
+# TYPE wakatime_leaderboard_rank gauge
+wakatime_leaderboard_rank 42
+# HELP wakatime_up wakatime_exporter: Whether all requests to Wakatime during the scrape succeeded.
+# TYPE wakatime_up gauge
+wakatime_up 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "wakatime_leaderboard_rank", "wakatime_up"); err != nil {
+		t.Error(err)
+	}
+	if n, err := testutil.GatherAndCount(reg, "wakatime_scrape_collector_age_seconds"); err != nil || n != 1 {
+		t.Errorf("got %d age metrics, err %v", n, err)
+	}
+}
+
+func TestPollIntervalInvalid(t *testing.T) {
+	in := newTestInputs(t, testAPIKey)
+	in.CollectorOptions = map[string]map[string]string{
+		leaderCollectorName: {"poll-interval": "0s"},
+	}
+	if _, err := NewWakaCollector(in, log.NewNopLogger(), leaderCollectorName); err == nil {
+		t.Error("got no error for a zero poll interval")
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The gauges are labelled by the credentials of the account whose requests
+// they describe, which is empty for the credentials given via flags.
+var (
+	rateLimitRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "rate_limit_remaining",
+			Help:      "wakatime_exporter: Requests remaining in the client-side rate limit budget.",
+		},
+		[]string{"credential", "window"},
+	)
+	upstreamRateLimitLimit = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "upstream_rate_limit_limit",
+			Help:      "wakatime_exporter: Request limit reported by the last Wakatime response.",
+		},
+		[]string{"credential"},
+	)
+	upstreamRateLimitRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "upstream_rate_limit_remaining",
+			Help:      "wakatime_exporter: Remaining requests reported by the last Wakatime response.",
+		},
+		[]string{"credential"},
+	)
+	upstreamRateLimitReset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "upstream_rate_limit_reset_timestamp_seconds",
+			Help:      "wakatime_exporter: Time at which the Wakatime rate limit resets, as reported by the last Wakatime response.",
+		},
+		[]string{"credential"},
+	)
+)
+
+func init() {
+	registerExporterMetrics(rateLimitRemaining, upstreamRateLimitLimit, upstreamRateLimitRemaining, upstreamRateLimitReset)
+}
+
+// ErrRateLimited indicates a request was not sent because the client-side
+// rate limit would not allow it before the deadline.
+var ErrRateLimited = errors.New("client-side rate limit exceeded")
+
+// RateLimiter limits requests to the Wakatime API, using a token bucket for
+// the rate per second and a sliding window for the daily quota. A single
+// RateLimiter should be shared by all collectors using the same account. It
+// is safe for concurrent use.
+type RateLimiter struct {
+	credential string
+
+	mtx    sync.Mutex
+	second tokenBucket
+	day    slidingWindow
+}
+
+type tokenBucket struct {
+	rate   float64 // tokens added per second, zero for unlimited
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// slidingWindow allows at most limit events within any period of the given
+// length.
+type slidingWindow struct {
+	limit  int // zero for unlimited
+	period time.Duration
+	// sent holds the times of the events within the last period, oldest
+	// first.
+	sent []time.Time
+}
+
+// NewRateLimiter returns a RateLimiter for the account with the given
+// credentials, allowing perSecond requests per second with bursts of up to
+// burst requests, and at most perDay requests within any 24 hours. A zero
+// perSecond or perDay disables the corresponding limit.
+func NewRateLimiter(credential string, perSecond float64, burst int, perDay int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		credential: credential,
+		second:     tokenBucket{rate: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()},
+		day:        slidingWindow{limit: perDay, period: 24 * time.Hour},
+	}
+}
+
+// Wait blocks until a request may be sent. It returns ErrRateLimited without
+// waiting if that would not happen before the context deadline.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait := l.reserve(time.Now())
+		if wait == 0 {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return ErrRateLimited
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token from both buckets if possible, otherwise it returns
+// how long to wait until both buckets will have a token.
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.second.refill(now)
+	l.day.expire(now)
+
+	wait := l.second.wait()
+	if w := l.day.wait(now); w > wait {
+		wait = w
+	}
+	if wait == 0 {
+		l.second.take()
+		l.day.take(now)
+	}
+
+	if l.second.rate > 0 {
+		rateLimitRemaining.WithLabelValues(l.credential, "second").Set(math.Floor(l.second.tokens))
+	}
+	if l.day.limit > 0 {
+		rateLimitRemaining.WithLabelValues(l.credential, "day").Set(float64(l.day.limit - len(l.day.sent)))
+	}
+	return wait
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+func (b *tokenBucket) wait() time.Duration {
+	if b.rate == 0 || b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take() {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens--
+}
+
+// expire forgets the events which are older than the period.
+func (w *slidingWindow) expire(now time.Time) {
+	i := 0
+	for i < len(w.sent) && !w.sent[i].After(now.Add(-w.period)) {
+		i++
+	}
+	w.sent = w.sent[i:]
+}
+
+// wait returns how long it is until another event is allowed.
+func (w *slidingWindow) wait(now time.Time) time.Duration {
+	if w.limit == 0 || len(w.sent) < w.limit {
+		return 0
+	}
+	return w.sent[len(w.sent)-w.limit].Add(w.period).Sub(now)
+}
+
+func (w *slidingWindow) take(now time.Time) {
+	if w.limit == 0 {
+		return
+	}
+	w.sent = append(w.sent, now)
+}
+
+// observeRateLimitHeaders records any rate limit headers sent by Wakatime in
+// response to a request using the given credentials.
+func observeRateLimitHeaders(credential string, h http.Header, now time.Time) {
+	if v, err := strconv.ParseFloat(h.Get("X-RateLimit-Limit"), 64); err == nil {
+		upstreamRateLimitLimit.WithLabelValues(credential).Set(v)
+	}
+	if v, err := strconv.ParseFloat(h.Get("X-RateLimit-Remaining"), 64); err == nil {
+		upstreamRateLimitRemaining.WithLabelValues(credential).Set(v)
+	}
+	if v, err := strconv.ParseFloat(h.Get("X-RateLimit-Reset"), 64); err == nil {
+		// The reset is either a Unix timestamp or a number of seconds from now.
+		if v < float64(now.Add(-24*time.Hour).Unix()) {
+			v += float64(now.Unix())
+		}
+		upstreamRateLimitReset.WithLabelValues(credential).Set(v)
+	}
+}
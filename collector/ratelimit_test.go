@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRateLimiterSecond(t *testing.T) {
+	l := NewRateLimiter("second-test", 1, 2, 0)
+	now := l.second.last
+
+	for i, want := range []time.Duration{0, 0, time.Second} {
+		if got := l.reserve(now); got != want {
+			t.Errorf("reserve %d = %s, want %s", i, got, want)
+		}
+	}
+	if got := l.reserve(now.Add(500 * time.Millisecond)); got != 500*time.Millisecond {
+		t.Errorf("reserve after 500ms = %s, want 500ms", got)
+	}
+	if got := l.reserve(now.Add(time.Second)); got != 0 {
+		t.Errorf("reserve after 1s = %s, want 0", got)
+	}
+	if got := testutil.ToFloat64(rateLimitRemaining.WithLabelValues("second-test", "second")); got != 0 {
+		t.Errorf("remaining = %v, want 0", got)
+	}
+}
+
+func TestRateLimiterDay(t *testing.T) {
+	l := NewRateLimiter("day-test", 0, 1, 3)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		at        time.Duration
+		want      time.Duration
+		remaining float64
+	}{
+		{0, 0, 2},
+		{time.Hour, 0, 1},
+		{2 * time.Hour, 0, 0},
+		{3 * time.Hour, 21 * time.Hour, 0},
+		// The first request leaves the window, allowing only one more.
+		{24 * time.Hour, 0, 0},
+		{24 * time.Hour, time.Hour, 0},
+		{26 * time.Hour, 0, 1},
+	} {
+		if got := l.reserve(start.Add(tc.at)); got != tc.want {
+			t.Errorf("reserve at %s = %s, want %s", tc.at, got, tc.want)
+		}
+		if got := testutil.ToFloat64(rateLimitRemaining.WithLabelValues("day-test", "day")); got != tc.remaining {
+			t.Errorf("remaining at %s = %v, want %v", tc.at, got, tc.remaining)
+		}
+	}
+}
+
+func TestObserveRateLimitHeaders(t *testing.T) {
+	now := time.Unix(1577836800, 0)
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", "100")
+	h.Set("X-RateLimit-Remaining", "7")
+	h.Set("X-RateLimit-Reset", "60")
+	observeRateLimitHeaders("headers-test", h, now)
+
+	for name, tc := range map[string]struct {
+		gauge *prometheus.GaugeVec
+		want  float64
+	}{
+		"limit":     {upstreamRateLimitLimit, 100},
+		"remaining": {upstreamRateLimitRemaining, 7},
+		"reset":     {upstreamRateLimitReset, float64(now.Unix() + 60)},
+	} {
+		if got := testutil.ToFloat64(tc.gauge.WithLabelValues("headers-test")); got != tc.want {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
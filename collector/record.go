@@ -0,0 +1,104 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// recordingName returns the file name under which the response for endpoint
+// of uri, e.g. the path of a user, with the given query parameters is
+// recorded.
+func recordingName(uri url.URL, endpoint string, params url.Values) string {
+	name := strings.ReplaceAll(strings.Trim(path.Join(uri.Path, endpoint), "/"), "/", "_")
+	if q := params.Encode(); q != "" {
+		name += "_" + url.PathEscape(q)
+	}
+	return name + ".json"
+}
+
+// recordFetch wraps fetch so that every response body is also saved to dir.
+func recordFetch(dir string, fetch FetchFunc, logger log.Logger) FetchFunc {
+	return func(ctx context.Context, uri url.URL, subPath string, params url.Values) (io.ReadCloser, error) {
+		body, err := fetch(ctx, uri, subPath, params)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, requestError(err)
+		}
+
+		file := filepath.Join(dir, recordingName(uri, subPath, params))
+		if err := writeRecording(file, data); err != nil {
+			level.Warn(logger).Log("msg", "Couldn't record Wakatime response", "path", subPath, "file", file, "err", err)
+		} else {
+			level.Debug(logger).Log("msg", "Recorded Wakatime response", "path", subPath, "file", file)
+		}
+
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// writeRecording atomically replaces file with data.
+func writeRecording(file string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".wakatime-recording")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// replayFetch serves responses previously saved by recordFetch from dir,
+// without sending any requests.
+func replayFetch(dir string, logger log.Logger) FetchFunc {
+	return func(ctx context.Context, uri url.URL, subPath string, params url.Values) (io.ReadCloser, error) {
+		file := filepath.Join(dir, recordingName(uri, subPath, params))
+		level.Info(logger).Log("msg", "Replaying Wakatime response", "path", subPath, "file", file)
+
+		f, err := os.Open(file)
+		if os.IsNotExist(err) {
+			return nil, &UpstreamError{Reason: ReasonNotFound, Err: fmt.Errorf("no recorded response in %s", file)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+}
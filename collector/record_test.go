@@ -0,0 +1,48 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+func TestRecordingName(t *testing.T) {
+	base := url.URL{Scheme: "https", Host: "wakatime.com", Path: "/api/v1"}
+	names := map[string]bool{}
+	for _, tt := range []struct {
+		uri      url.URL
+		endpoint string
+		params   url.Values
+		want     string
+	}{
+		{wakatime.UserPath(base, "current"), "stats/all_time", nil, "api_v1_users_current_stats_all_time.json"},
+		{wakatime.UserPath(base, "alice"), "stats/all_time", nil, "api_v1_users_alice_stats_all_time.json"},
+		{wakatime.UserPath(base, "alice"), "goals", url.Values{"page": {"2"}}, "api_v1_users_alice_goals_page=2.json"},
+		{base, "leaders", url.Values{"cache": {"false"}}, "api_v1_leaders_cache=false.json"},
+	} {
+		got := recordingName(tt.uri, tt.endpoint, tt.params)
+		if got != tt.want {
+			t.Errorf("recordingName(%s, %s, %v) = %s, want %s", tt.uri.Path, tt.endpoint, tt.params, got, tt.want)
+		}
+		if names[got] {
+			t.Errorf("recordingName(%s, %s, %v) = %s, which is not unique", tt.uri.Path, tt.endpoint, tt.params, got)
+		}
+		names[got] = true
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var retriesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "request_retries_total",
+		Help:      "wakatime_exporter: Number of retried requests to the Wakatime API.",
+	},
+	[]string{"endpoint"},
+)
+
+func init() {
+	registerExporterMetrics(retriesTotal)
+}
+
+// RetryPolicy controls how failed requests to the Wakatime API are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// do calls send until it succeeds or is not modified, fails with a
+// non-retryable status, runs out of attempts, or the next attempt would not
+// complete before the context deadline.
+func (p RetryPolicy) do(ctx context.Context, send func(context.Context) (*http.Response, error), endpoint string, logger log.Logger) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := send(ctx)
+		if err == nil && (resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified) {
+			return resp, nil
+		}
+
+		wait := p.backoff(attempt)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, ErrRateLimited) {
+				return nil, err
+			}
+		} else {
+			resp.Body.Close()
+			err = statusError(resp.StatusCode)
+			if !isRetryableStatus(resp.StatusCode) {
+				return nil, err
+			}
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					wait = d
+				}
+			}
+		}
+
+		if attempt >= p.MaxAttempts {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			level.Debug(logger).Log("msg", "Not retrying, deadline would be exceeded", "path", endpoint, "wait", wait, "err", err)
+			return nil, err
+		}
+
+		level.Warn(logger).Log("msg", "Retrying Wakatime request", "path", endpoint, "attempt", attempt, "wait", wait, "err", err)
+		retriesTotal.WithLabelValues(endpoint).Inc()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the time to wait after the given (1-indexed) failed attempt,
+// using exponential backoff with jitter.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Wait between half and all of the computed backoff, so that concurrent
+	// collectors do not retry in lockstep.
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header value, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		10: time.Second,
+	} {
+		for i := 0; i < 100; i++ {
+			if got := p.backoff(attempt); got < want/2 || got > want {
+				t.Fatalf("attempt %d: got backoff %s, want between %s and %s", attempt, got, want/2, want)
+			}
+		}
+	}
+
+	if got := (RetryPolicy{}).backoff(3); got != 0 {
+		t.Errorf("got backoff %s without an initial backoff", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, test := range tests {
+		got, ok := retryAfter(test.value, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%q: got %s, %v, want %s, %v", test.value, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// sendStatuses returns a send function responding with the given statuses in
+// order, and a pointer to the number of calls.
+func sendStatuses(statuses ...int) (func(context.Context) (*http.Response, error), *int) {
+	calls := 0
+	return func(context.Context) (*http.Response, error) {
+		code := statuses[calls]
+		calls++
+		header := http.Header{}
+		if code == http.StatusTooManyRequests {
+			header.Set("Retry-After", "10")
+		}
+		return &http.Response{
+			StatusCode: code,
+			Header:     header,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}, &calls
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", []int{200}, 1, false},
+		{"not modified", []int{304}, 1, false},
+		{"retried", []int{503, 502, 200}, 3, false},
+		{"not retryable", []int{404}, 1, true},
+		{"out of attempts", []int{500, 500, 500}, 3, true},
+	}
+	for _, test := range tests {
+		endpoint := "retry-test-" + strings.Replace(test.name, " ", "-", -1)
+		send, calls := sendStatuses(test.statuses...)
+		before := testutil.ToFloat64(retriesTotal.WithLabelValues(endpoint))
+		_, err := p.do(context.Background(), send, endpoint, log.NewNopLogger())
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+		if *calls != test.wantCalls {
+			t.Errorf("%s: got %d attempts, want %d", test.name, *calls, test.wantCalls)
+		}
+		if got := testutil.ToFloat64(retriesTotal.WithLabelValues(endpoint)) - before; got != float64(test.wantCalls-1) {
+			t.Errorf("%s: got %v retries, want %d", test.name, got, test.wantCalls-1)
+		}
+	}
+}
+
+func TestRetryPolicyDoDeadline(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Retry-After asks for 10s, which would exceed the deadline.
+	send, calls := sendStatuses(http.StatusTooManyRequests, http.StatusOK)
+	begin := time.Now()
+	if _, err := p.do(ctx, send, "retry-test-deadline", log.NewNopLogger()); err == nil {
+		t.Error("got no error")
+	}
+	if *calls != 1 {
+		t.Errorf("got %d attempts, want 1", *calls)
+	}
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Errorf("waited %s for a retry which could not complete", elapsed)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kinds of schema drift, used as the kind label of
+// wakatime_exporter_schema_drift_total.
+const (
+	driftUnknown  = "unknown"
+	driftMissing  = "missing"
+	driftMistyped = "mistyped"
+)
+
+var schemaDriftTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "schema_drift_total",
+		Help:      "wakatime_exporter: Number of responses containing a field which is unknown, missing or of an unexpected type.",
+	},
+	[]string{"endpoint", "kind", "field"},
+)
+
+func init() {
+	registerExporterMetrics(schemaDriftTotal)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// checkSchema compares a JSON response from endpoint with the type of v,
+// which it is decoded into, and reports any fields which differ.
+func checkSchema(endpoint string, data []byte, v interface{}, logger log.Logger) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+
+	drift := map[string]string{}
+	compareSchema(reflect.TypeOf(v), raw, "", drift)
+
+	fields := make([]string, 0, len(drift))
+	for field := range drift {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		kind := drift[field]
+		level.Debug(logger).Log("msg", "Wakatime response does not match schema", "path", endpoint, "kind", kind, "field", field)
+		schemaDriftTotal.WithLabelValues(endpoint, kind, field).Inc()
+	}
+}
+
+// compareSchema records in drift the fields of raw, a decoded JSON value at
+// path, which do not match the type t.
+func compareSchema(t reflect.Type, raw interface{}, path string, drift map[string]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if raw == nil || t.Kind() == reflect.Interface {
+		return
+	}
+
+	switch {
+	case t == timeType:
+		if _, ok := raw.(string); !ok {
+			drift[path] = driftMistyped
+		}
+	case t.Kind() == reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			drift[path] = driftMistyped
+			return
+		}
+		fields := jsonFields(t)
+		for name, value := range obj {
+			field, ok := fields[name]
+			if !ok {
+				drift[joinPath(path, name)] = driftUnknown
+				continue
+			}
+			compareSchema(field, value, joinPath(path, name), drift)
+		}
+		for name := range fields {
+			if _, ok := obj[name]; !ok {
+				drift[joinPath(path, name)] = driftMissing
+			}
+		}
+	case t.Kind() == reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			drift[path] = driftMistyped
+			return
+		}
+		for _, item := range items {
+			compareSchema(t.Elem(), item, path+"[]", drift)
+		}
+	case t.Kind() == reflect.String:
+		if _, ok := raw.(string); !ok {
+			drift[path] = driftMistyped
+		}
+	case t.Kind() == reflect.Bool:
+		if _, ok := raw.(bool); !ok {
+			drift[path] = driftMistyped
+		}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Float64:
+		if _, ok := raw.(float64); !ok {
+			drift[path] = driftMistyped
+		}
+	}
+}
+
+// jsonFields returns the types of the fields of struct type t by JSON name,
+// including the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			for name, ft := range jsonFields(f.Type) {
+				fields[name] = ft
+			}
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
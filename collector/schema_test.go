@@ -0,0 +1,145 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type schemaTestProject struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"total_seconds"`
+}
+
+type schemaTestResponse struct {
+	Range struct {
+		Start time.Time `json:"start"`
+	} `json:"range"`
+	Public   bool                `json:"is_public"`
+	Projects []schemaTestProject `json:"projects"`
+	Owner    *schemaTestProject  `json:"owner"`
+	Extra    interface{}         `json:"extra"`
+	Ignored  string              `json:"-"`
+}
+
+// schemaDriftKey identifies a series of schemaDriftTotal for an endpoint.
+type schemaDriftKey struct {
+	field, kind string
+}
+
+// schemaDrift returns the schema drift counted so far for endpoint. The
+// counters are global, so tests compare them against an earlier call.
+func schemaDrift(t *testing.T, endpoint string) map[schemaDriftKey]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		schemaDriftTotal.Collect(ch)
+		close(ch)
+	}()
+
+	drift := map[schemaDriftKey]float64{}
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		labels := map[string]string{}
+		for _, l := range pb.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["endpoint"] != endpoint {
+			continue
+		}
+		drift[schemaDriftKey{labels["field"], labels["kind"]}] = pb.GetCounter().GetValue()
+	}
+	return drift
+}
+
+func TestCheckSchema(t *testing.T) {
+	const valid = `"range": {"start": "2020-01-01T00:00:00Z"}, "is_public": true, "owner": null, "extra": [1]`
+	tests := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{
+			name: "valid",
+			data: `{` + valid + `, "projects": [{"name": "a", "total_seconds": 1}]}`,
+			want: map[string]string{},
+		},
+		{
+			name: "unknown",
+			data: `{` + valid + `, "projects": [{"name": "a", "total_seconds": 1, "color": "red"}], "Ignored": "x", "new": 1}`,
+			want: map[string]string{
+				"projects[].color": driftUnknown,
+				"Ignored":          driftUnknown,
+				"new":              driftUnknown,
+			},
+		},
+		{
+			name: "missing",
+			data: `{"range": {}, "projects": [{"name": "a"}], "owner": {"total_seconds": 1}}`,
+			want: map[string]string{
+				"range.start":              driftMissing,
+				"is_public":                driftMissing,
+				"extra":                    driftMissing,
+				"projects[].total_seconds": driftMissing,
+				"owner.name":               driftMissing,
+			},
+		},
+		{
+			name: "mistyped",
+			data: `{"range": {"start": 1577836800}, "is_public": "yes", "projects": {"name": "a"}, "owner": {"name": 1, "total_seconds": "1"}, "extra": "x"}`,
+			want: map[string]string{
+				"range.start":         driftMistyped,
+				"is_public":           driftMistyped,
+				"projects":            driftMistyped,
+				"owner.name":          driftMistyped,
+				"owner.total_seconds": driftMistyped,
+			},
+		},
+		{
+			name: "not json",
+			data: `<html>`,
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		endpoint := "schema-test-" + tt.name
+		before := schemaDrift(t, endpoint)
+		checkSchema(endpoint, []byte(tt.data), &schemaTestResponse{}, log.NewNopLogger())
+
+		got := map[string]string{}
+		for k, v := range schemaDrift(t, endpoint) {
+			switch d := v - before[k]; d {
+			case 0:
+			case 1:
+				got[k.field] = k.kind
+			default:
+				t.Errorf("%s: field %s counted %v times, want 1", tt.name, k.field, d)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got drift %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
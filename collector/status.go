@@ -0,0 +1,135 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CollectorStatus is the outcome of the latest runs of a collector using the
+// same credentials for the same user.
+type CollectorStatus struct {
+	// Credential names the credentials used, and URI is the user queried, as
+	// in CommonInputs.
+	Credential string
+	URI        string
+	Collector  string
+	// LastRun is when the latest run completed, and LastSuccess when the
+	// latest successful run did, if any.
+	LastRun      time.Time
+	LastSuccess  time.Time
+	LastDuration time.Duration
+	// Reason and Err describe why the latest run failed, and are empty if
+	// it succeeded.
+	Reason string
+	Err    string
+}
+
+type statusKey struct {
+	credential, uri, collector string
+}
+
+// maxStatuses is the number of statuses kept. Once it is reached, the status
+// of the least recently run collector is dropped, so that probes of many
+// targets cannot grow the statuses without bound.
+const maxStatuses = 1024
+
+var (
+	statusMtx sync.Mutex
+	statuses  = make(map[statusKey]*CollectorStatus)
+	// statusMaxAge is how long the status of a collector which has not run
+	// since is kept. Zero keeps it until it is dropped via maxStatuses.
+	statusMaxAge time.Duration
+)
+
+// SetStatusMaxAge sets how long the status of a collector which has not run
+// since is kept.
+func SetStatusMaxAge(d time.Duration) {
+	statusMtx.Lock()
+	defer statusMtx.Unlock()
+	statusMaxAge = d
+}
+
+// recordStatus records the outcome of a run of a collector. Runs abandoned
+// because their scrape or poller went away are not recorded, since they say
+// nothing about Wakatime.
+func recordStatus(ctx context.Context, credential, uri, collector string, duration time.Duration, err error) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+
+	now := time.Now()
+	statusMtx.Lock()
+	defer statusMtx.Unlock()
+	key := statusKey{credential, uri, collector}
+	s, ok := statuses[key]
+	if !ok {
+		pruneStatuses(now)
+		s = &CollectorStatus{Credential: credential, URI: uri, Collector: collector}
+		statuses[key] = s
+	}
+	s.LastRun = now
+	s.LastDuration = duration
+	s.Reason, s.Err = "", ""
+	if err != nil && !isNoDataError(err) {
+		s.Reason, s.Err = errorReason(err), err.Error()
+	} else {
+		s.LastSuccess = s.LastRun
+	}
+}
+
+// pruneStatuses drops the statuses older than statusMaxAge, and the least
+// recently run one if there is no room for another. statusMtx must be held.
+func pruneStatuses(now time.Time) {
+	var oldest statusKey
+	for k, s := range statuses {
+		if statusMaxAge > 0 && now.Sub(s.LastRun) > statusMaxAge {
+			delete(statuses, k)
+			continue
+		}
+		if o, ok := statuses[oldest]; !ok || s.LastRun.Before(o.LastRun) {
+			oldest = k
+		}
+	}
+	if len(statuses) >= maxStatuses {
+		delete(statuses, oldest)
+	}
+}
+
+// Statuses returns the status of every collector which has run, sorted by
+// credentials, user and collector.
+func Statuses() []CollectorStatus {
+	statusMtx.Lock()
+	defer statusMtx.Unlock()
+	all := make([]CollectorStatus, 0, len(statuses))
+	for _, s := range statuses {
+		all = append(all, *s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Credential != all[j].Credential {
+			return all[i].Credential < all[j].Credential
+		}
+		if all[i].URI != all[j].URI {
+			return all[i].URI < all[j].URI
+		}
+		return all[i].Collector < all[j].Collector
+	})
+	return all
+}
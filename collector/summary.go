@@ -16,18 +16,18 @@ limitations under the License.
 package collector
 
 import (
-	"io"
-	"net/url"
+	"context"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
 )
 
 const (
 	summaryCollectorName = "summary"
 	summaryMetricName    = "seconds_total"
-	summaryEndpoint      = "summaries"
 )
 
 type summaryCollector struct {
@@ -38,17 +38,32 @@ type summaryCollector struct {
 	editor          *prometheus.Desc
 	project         *prometheus.Desc
 	category        *prometheus.Desc
-	uri             url.URL
-	fetchStat       func(url.URL, string, url.Values) (io.ReadCloser, error)
+	client          *wakatime.Client
 	logger          log.Logger
 }
 
+var (
+	summaryCacheTTL = collectorFlag(summaryCollectorName, "cache-ttl",
+		"How long to serve summaries from cache before querying Wakatime again (0 disables caching).",
+	).Default("0s").Duration()
+	summaryPollInterval = collectorFlag(summaryCollectorName, "poll-interval",
+		"How often to query summaries in background polling mode.",
+	).Default("1m").Duration()
+)
+
 func init() {
 	registerCollector(summaryCollectorName, defaultEnabled, NewSummaryCollector)
+	registerPollInterval(summaryCollectorName, summaryPollInterval)
+	registerUserEndpoint(summaryCollectorName, wakatime.SummariesEndpoint)
 }
 
 // NewSummaryCollector returns a new Collector exposing all-time stats.
 func NewSummaryCollector(in CommonInputs, logger log.Logger) (Collector, error) {
+	cacheTTL, err := in.durationOption(summaryCollectorName, "cache-ttl", *summaryCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	return &summaryCollector{
 		total: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", summaryMetricName),
@@ -85,30 +100,21 @@ func NewSummaryCollector(in CommonInputs, logger log.Logger) (Collector, error)
 			"Total seconds for each category.",
 			[]string{"name"}, nil,
 		),
-		uri:       in.URI,
-		fetchStat: FetchHTTP(in.Token, in.SSLVerify, in.Timeout, logger),
-		logger:    logger,
+		client: newClient(in, cachedFetch(in.Cache, cacheTTL, FetchHTTP(in, logger)), logger),
+		logger: logger,
 	}, nil
 }
 
-func (c *summaryCollector) Update(ch chan<- prometheus.Metric) error {
-	params := url.Values{}
-	params.Add("start", "today")
-	params.Add("end", "today")
-	params.Add("cache", "false")
-
-	body, fetchErr := c.fetchStat(c.uri, summaryEndpoint, params)
-	if fetchErr != nil {
-		return fetchErr
-	}
-
-	summaryStats := wakatimeSummary{}
-	if err := ReadAndUnmarshal(body, &summaryStats); err != nil {
+func (c *summaryCollector) Update(ctx context.Context, ch chan<- prometheus.Metric) error {
+	summaryStats, err := c.client.Summaries(ctx, wakatime.SummariesOptions{
+		Start:   "today",
+		End:     "today",
+		NoCache: true,
+	})
+	if err != nil {
 		return err
 	}
 
-	defer body.Close()
-
 	for i, data := range summaryStats.Data {
 		level.Info(c.logger).Log(
 			"msg", "Collecting summary from Wakatime",
@@ -124,6 +130,9 @@ func (c *summaryCollector) Update(ch chan<- prometheus.Metric) error {
 	if resultLength != 1 {
 		level.Error(c.logger).Log("msg", "length of results is incorrect", "size", resultLength)
 	}
+	if resultLength == 0 {
+		return ErrNoData
+	}
 	todaySummaryStats := summaryStats.Data[0]
 
 	ch <- prometheus.MustNewConstMetric(
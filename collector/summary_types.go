@@ -1,112 +0,0 @@
-/*
-Copyright 2020 Jacob Colvin (MacroPower)
-Licensed under the Apache License, Version 2.0 (the "License");
-you may not use this file except in compliance with the License.
-You may obtain a copy of the License at
-
-http://www.apache.org/licenses/LICENSE-2.0
-
-Unless required by applicable law or agreed to in writing, software
-distributed under the License is distributed on an "AS IS" BASIS,
-WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-See the License for the specific language governing permissions and
-limitations under the License.
-*/
-
-package collector
-
-import (
-	"time"
-)
-
-type wakatimeSummary struct {
-	Data []struct {
-		Categories []struct {
-			Digital      string  `json:"digital"`
-			Hours        int     `json:"hours"`
-			Minutes      int     `json:"minutes"`
-			Name         string  `json:"name"`
-			Percent      float64 `json:"percent"`
-			Seconds      int     `json:"seconds"`
-			Text         string  `json:"text"`
-			TotalSeconds float64 `json:"total_seconds"`
-		} `json:"categories"`
-		Dependencies []struct {
-			Digital      string  `json:"digital"`
-			Hours        int     `json:"hours"`
-			Minutes      int     `json:"minutes"`
-			Name         string  `json:"name"`
-			Percent      float64 `json:"percent"`
-			Seconds      int     `json:"seconds"`
-			Text         string  `json:"text"`
-			TotalSeconds float64 `json:"total_seconds"`
-		} `json:"dependencies"`
-		Editors []struct {
-			Digital      string  `json:"digital"`
-			Hours        int     `json:"hours"`
-			Minutes      int     `json:"minutes"`
-			Name         string  `json:"name"`
-			Percent      float64 `json:"percent"`
-			Seconds      int     `json:"seconds"`
-			Text         string  `json:"text"`
-			TotalSeconds float64 `json:"total_seconds"`
-		} `json:"editors"`
-		GrandTotal struct {
-			Digital      string  `json:"digital"`
-			Hours        int     `json:"hours"`
-			Minutes      int     `json:"minutes"`
-			Text         string  `json:"text"`
-			TotalSeconds float64 `json:"total_seconds"`
-		} `json:"grand_total"`
-		Languages []struct {
-			Digital      string  `json:"digital"`
-			Hours        int     `json:"hours"`
-			Minutes      int     `json:"minutes"`
-			Name         string  `json:"name"`
-			Percent      float64 `json:"percent"`
-			Seconds      int     `json:"seconds"`
-			Text         string  `json:"text"`
-			TotalSeconds float64 `json:"total_seconds"`
-		} `json:"languages"`
-		Machines []struct {
-			Digital       string  `json:"digital"`
-			Hours         int     `json:"hours"`
-			MachineNameID string  `json:"machine_name_id"`
-			Minutes       int     `json:"minutes"`
-			Name          string  `json:"name"`
-			Percent       float64 `json:"percent"`
-			Seconds       int     `json:"seconds"`
-			Text          string  `json:"text"`
-			TotalSeconds  float64 `json:"total_seconds"`
-		} `json:"machines"`
-		OperatingSystems []struct {
-			Digital      string  `json:"digital"`
-			Hours        int     `json:"hours"`
-			Minutes      int     `json:"minutes"`
-			Name         string  `json:"name"`
-			Percent      float64 `json:"percent"`
-			Seconds      int     `json:"seconds"`
-			Text         string  `json:"text"`
-			TotalSeconds float64 `json:"total_seconds"`
-		} `json:"operating_systems"`
-		Projects []struct {
-			Digital      string  `json:"digital"`
-			Hours        int     `json:"hours"`
-			Minutes      int     `json:"minutes"`
-			Name         string  `json:"name"`
-			Percent      float64 `json:"percent"`
-			Seconds      int     `json:"seconds"`
-			Text         string  `json:"text"`
-			TotalSeconds float64 `json:"total_seconds"`
-		} `json:"projects"`
-		Range struct {
-			Date     string    `json:"date"`
-			End      time.Time `json:"end"`
-			Start    time.Time `json:"start"`
-			Text     string    `json:"text"`
-			Timezone string    `json:"timezone"`
-		} `json:"range"`
-	} `json:"data"`
-	End   time.Time `json:"end"`
-	Start time.Time `json:"start"`
-}
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+// Config is the format of the file given by --config.file.
+type Config struct {
+	Accounts []AccountConfig `yaml:"accounts"`
+}
+
+// AccountConfig describes a Wakatime account to scrape. Fields which are not
+// set default to the corresponding flags.
+type AccountConfig struct {
+	// Name identifies the account, and selects it in probes.
+	Name        string            `yaml:"name"`
+	BaseURI     string            `yaml:"base_uri,omitempty"`
+	User        string            `yaml:"user,omitempty"`
+	Credentials CredentialsConfig `yaml:"credentials,omitempty"`
+	// Collectors are the collectors to enable for the account.
+	Collectors []string `yaml:"collectors,omitempty"`
+	// CollectorOptions override the option flags of each collector, by
+	// collector and option name, e.g. "goal" and "max-pages".
+	CollectorOptions map[string]map[string]string `yaml:"collector_options,omitempty"`
+	// Labels are added to every metric of the account.
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// CredentialsConfig selects how an account authenticates with Wakatime. At
+// most one field may be set.
+type CredentialsConfig struct {
+	APIKey       string `yaml:"api_key,omitempty"`
+	APIKeyFile   string `yaml:"api_key_file,omitempty"`
+	UseCLIConfig bool   `yaml:"use_cli_config,omitempty"`
+}
+
+// account is a Wakatime account scraped via the metrics path.
+type account struct {
+	name   string
+	inputs collector.CommonInputs
+	// labels are added to every metric of the account.
+	labels prometheus.Labels
+}
+
+// loadConfig reads and validates the config file at path.
+func loadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("couldn't parse %s: %s", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// validate checks the parts of the config which do not depend on the
+// collectors. These are checked when the collectors are created.
+func (c *Config) validate() error {
+	if len(c.Accounts) == 0 {
+		return errors.New("no accounts")
+	}
+
+	names := make(map[string]bool)
+	labelSets := make(map[string]string)
+	var labelNames []string
+	for i, a := range c.Accounts {
+		if a.Name == "" {
+			return fmt.Errorf("account %d has no name", i+1)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("duplicate account %s", a.Name)
+		}
+		names[a.Name] = true
+
+		if a.BaseURI != "" {
+			if u, err := url.Parse(a.BaseURI); err != nil || !u.IsAbs() {
+				return fmt.Errorf("account %s: invalid base_uri %q", a.Name, a.BaseURI)
+			}
+		}
+
+		sources := 0
+		for _, set := range []bool{a.Credentials.APIKey != "", a.Credentials.APIKeyFile != "", a.Credentials.UseCLIConfig} {
+			if set {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return fmt.Errorf("account %s: only one of api_key, api_key_file and use_cli_config can be set", a.Name)
+		}
+
+		// Accounts must have the same label names, so that their metrics
+		// can be exposed together, and different label values, so that
+		// they can be told apart.
+		ln := make([]string, 0, len(a.Labels))
+		for name := range a.Labels {
+			if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+				return fmt.Errorf("account %s: invalid label name %q", a.Name, name)
+			}
+			ln = append(ln, name)
+		}
+		sort.Strings(ln)
+		if i == 0 {
+			labelNames = ln
+		} else if strings.Join(ln, ",") != strings.Join(labelNames, ",") {
+			return fmt.Errorf("account %s: labels must have the same names in every account (%s)", a.Name, strings.Join(labelNames, ", "))
+		}
+		if len(c.Accounts) > 1 {
+			values := make([]string, len(ln))
+			for j, name := range ln {
+				values[j] = a.Labels[name]
+			}
+			key := strings.Join(values, "\xff")
+			if other, ok := labelSets[key]; ok {
+				return fmt.Errorf("accounts %s and %s have the same labels", other, a.Name)
+			}
+			labelSets[key] = a.Name
+		}
+	}
+	return nil
+}
+
+// accountInputs returns in for the account or named credentials with the
+// given name. Each account has its own rate limit, and responses for the same
+// user differ between accounts, so neither can be shared.
+func accountInputs(in collector.CommonInputs, name string, newRateLimiter func(credential string) *collector.RateLimiter) collector.CommonInputs {
+	in.Credential = name
+	in.Cache = collector.NewResponseCache()
+	in.RateLimiter = newRateLimiter(name)
+	return in
+}
+
+// newAccounts returns the accounts described by cfg. Fields which are not set
+// are taken from defaults, which holds the inputs given via flags, and
+// defaultUser. defaultAuthErr is reported for accounts without credentials if
+// the flags did not give any either.
+func newAccounts(cfg *Config, defaults collector.CommonInputs, defaultUser string, defaultAuthErr error, newRateLimiter func(credential string) *collector.RateLimiter) ([]account, error) {
+	accounts := make([]account, 0, len(cfg.Accounts))
+	for _, a := range cfg.Accounts {
+		in := accountInputs(defaults, a.Name, newRateLimiter)
+		in.Collectors = a.Collectors
+		in.CollectorOptions = a.CollectorOptions
+
+		if a.BaseURI != "" {
+			u, err := url.Parse(a.BaseURI)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %s", a.Name, err)
+			}
+			in.BaseURI = *u
+		}
+		user := defaultUser
+		if a.User != "" {
+			user = a.User
+		}
+		in.URI = wakatime.UserPath(in.BaseURI, user)
+
+		var err error
+		switch {
+		case a.Credentials.APIKey != "":
+			in.Auth = collector.BasicAuth{APIKey: a.Credentials.APIKey}
+		case a.Credentials.APIKeyFile != "":
+			in.Auth, err = collector.NewAPIKeyFile(a.Credentials.APIKeyFile)
+		case a.Credentials.UseCLIConfig:
+			var path string
+			if path, err = collector.DefaultCLIConfigPath(); err == nil {
+				in.Auth, err = collector.NewCLIConfigAPIKey(path)
+			}
+		default:
+			err = defaultAuthErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("account %s: couldn't load credentials: %s", a.Name, err)
+		}
+
+		accounts = append(accounts, account{name: a.Name, inputs: in, labels: a.Labels})
+	}
+	return accounts, nil
+}
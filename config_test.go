@@ -0,0 +1,177 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+	"github.com/MacroPower/wakatime_exporter/fakeserver"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+func writeConfig(t *testing.T, config string) string {
+	dir, err := ioutil.TempDir("", "wakatime_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigExample(t *testing.T) {
+	cfg, err := loadConfig("docs/example/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Accounts) != 2 || cfg.Accounts[1].CollectorOptions["goal"]["max-pages"] != "2" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no accounts":   `accounts: []`,
+		"unknown field": `accounts: [{name: a, api_key: x}]`,
+		"no name":       `accounts: [{user: a}]`,
+		"duplicate":     `accounts: [{name: a}, {name: a}]`,
+		"base uri":      `accounts: [{name: a, base_uri: "wakatime.com"}]`,
+		"credentials":   `accounts: [{name: a, credentials: {api_key: x, api_key_file: y}}]`,
+		"label name":    `accounts: [{name: a, labels: {__team: x}}]`,
+		"label names":   `accounts: [{name: a, labels: {team: x}}, {name: b, labels: {group: y}}]`,
+		"label values":  `accounts: [{name: a, labels: {team: x}}, {name: b, labels: {team: x}}]`,
+		"same labels":   `accounts: [{name: a}, {name: b}]`,
+	}
+	for name, config := range tests {
+		if _, err := loadConfig(writeConfig(t, config)); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestConfigAccounts(t *testing.T) {
+	api := httptest.NewServer(fakeserver.New("secret"))
+	defer api.Close()
+	base, err := url.Parse(api.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(writeConfig(t, `
+accounts:
+  - name: alice
+    credentials:
+      api_key: secret
+    collectors: [leader]
+    labels:
+      person: alice
+  - name: bob
+    user: bob
+    collectors: [leader, all-time]
+    collector_options:
+      leader:
+        cache-ttl: 1m
+    labels:
+      person: bob
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := collector.CommonInputs{
+		BaseURI: *base,
+		Timeout: 5 * time.Second,
+		Retry:   collector.RetryPolicy{MaxAttempts: 1},
+	}
+	newRateLimiter := func(string) *collector.RateLimiter { return nil }
+	noAuth := errors.New("no credentials")
+	if _, err := newAccounts(cfg, defaults, wakatime.CurrentUser, noAuth, newRateLimiter); err == nil {
+		t.Fatal("got no error for an account without credentials")
+	}
+
+	defaults.Auth = collector.BasicAuth{APIKey: "secret"}
+	accounts, err := newAccounts(cfg, defaults, wakatime.CurrentUser, nil, newRateLimiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHandler(accounts, false, false, 0, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`wakatime_leaderboard_rank{person="alice"} 42`,
+		`wakatime_leaderboard_rank{person="bob"} 42`,
+		`wakatime_cumulative_seconds_total{person="bob"} 1.234567e+06`,
+		`wakatime_up{person="alice"} 1`,
+		`wakatime_up{person="bob"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("got metrics without %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(string(body), `wakatime_cumulative_seconds_total{person="alice"}`) {
+		t.Error("got all-time metrics for alice, whose all-time collector is disabled")
+	}
+}
+
+func TestConfigAccountsInvalidCollectors(t *testing.T) {
+	tests := map[string]string{
+		"collector": `accounts: [{name: a, collectors: [bogus]}]`,
+		"option":    `accounts: [{name: a, collector_options: {goal: {bogus: "1"}}}]`,
+		"value":     `accounts: [{name: a, collector_options: {goal: {max-pages: many}}}]`,
+	}
+	for name, config := range tests {
+		cfg, err := loadConfig(writeConfig(t, config))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		accounts, err := newAccounts(cfg, collector.CommonInputs{}, wakatime.CurrentUser, nil, func(string) *collector.RateLimiter { return nil })
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if _, err := newHandler(accounts, false, false, 0, log.NewNopLogger()); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+// The synthetic data served by Server. It never changes, so that tests can
+// rely on exact values.
+const (
+	// GoalsPerPage is the number of goals on each page of the goals endpoint.
+	GoalsPerPage = 2
+	// LeaderPages is the number of pages of the leaders endpoint.
+	LeaderPages = 3
+	// LeaderRank is the rank of the current user on the leaderboard.
+	LeaderRank = 42
+	// AllTimeSeconds is the user's total coding time.
+	AllTimeSeconds = 1234567
+)
+
+// Day is the day which the synthetic summaries and goals are reported for.
+var Day = time.Date(2020, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+var allGoals = []struct {
+	id, title, delta string
+	seconds          int
+	actual           float64
+}{
+	{id: "goal-1", title: "Code 2 hrs per day", delta: "day", seconds: 7200, actual: 5400},
+	{id: "goal-2", title: "Code 10 hrs per week in Go", delta: "week", seconds: 36000, actual: 21600},
+	{id: "goal-3", title: "Code 1 hr per day on dotfiles", delta: "day", seconds: 3600, actual: 900},
+}
+
+func item(name string, seconds, total float64) wakatime.SummaryItem {
+	return wakatime.SummaryItem{
+		Digital:      fmt.Sprintf("%d:%02d", int(seconds)/3600, int(seconds)%3600/60),
+		Hours:        int(seconds) / 3600,
+		Minutes:      int(seconds) % 3600 / 60,
+		Name:         name,
+		Percent:      seconds / total * 100,
+		Text:         fmt.Sprintf("%d hrs %d mins", int(seconds)/3600, int(seconds)%3600/60),
+		TotalSeconds: seconds,
+	}
+}
+
+func dayRange() wakatime.Range {
+	return wakatime.Range{
+		Date:     Day.Format("2006-01-02"),
+		Start:    Day,
+		End:      Day.Add(24*time.Hour - time.Second),
+		Text:     "Today",
+		Timezone: "UTC",
+	}
+}
+
+func summaries() *wakatime.Summaries {
+	const total = 7200
+	return &wakatime.Summaries{
+		Start: Day,
+		End:   Day.Add(24*time.Hour - time.Second),
+		Data: []wakatime.Summary{{
+			Categories:   []wakatime.SummaryItem{item("Coding", total, total)},
+			Dependencies: []wakatime.SummaryItem{},
+			Editors: []wakatime.SummaryItem{
+				item("VS Code", 5400, total),
+				item("Vim", 1800, total),
+			},
+			GrandTotal: wakatime.GrandTotal{
+				Digital:      "2:00",
+				Hours:        2,
+				Text:         "2 hrs",
+				TotalSeconds: total,
+			},
+			Languages: []wakatime.SummaryItem{
+				item("Go", 3600, total),
+				item("Python", 1800, total),
+				item("Markdown", 1800, total),
+			},
+			Machines: []wakatime.MachineSummaryItem{
+				{SummaryItem: item("laptop", total, total), MachineNameID: "machine-1"},
+			},
+			OperatingSystems: []wakatime.SummaryItem{item("Linux", total, total)},
+			Projects: []wakatime.SummaryItem{
+				item("wakatime_exporter", 5400, total),
+				item("dotfiles", 1800, total),
+			},
+			Range: dayRange(),
+		}},
+	}
+}
+
+func goals(page int) *wakatime.Goals {
+	totalPages := (len(allGoals) + GoalsPerPage - 1) / GoalsPerPage
+	resp := &wakatime.Goals{
+		Data:       []wakatime.Goal{},
+		Total:      len(allGoals),
+		TotalPages: totalPages,
+	}
+	for i := (page - 1) * GoalsPerPage; i < page*GoalsPerPage && i < len(allGoals); i++ {
+		g := allGoals[i]
+		resp.Data = append(resp.Data, wakatime.Goal{
+			ChartData: []wakatime.GoalChartData{
+				{ActualSeconds: g.actual / 2, GoalSeconds: g.seconds, Range: dayRange(), RangeStatus: "fail"},
+				{ActualSeconds: g.actual, GoalSeconds: g.seconds, Range: dayRange(), RangeStatus: "pending"},
+			},
+			CreatedAt:          Day.AddDate(0, -1, 0),
+			Delta:              g.delta,
+			Editors:            []interface{}{},
+			ID:                 g.id,
+			IgnoreDays:         []interface{}{},
+			IsCurrentUserOwner: true,
+			IsEnabled:          true,
+			Languages:          []string{},
+			Projects:           []interface{}{},
+			RangeText:          "today",
+			Seconds:            g.seconds,
+			SharedWith:         []interface{}{},
+			Status:             "pending",
+			Subscribers:        []wakatime.GoalSubscriber{},
+			Title:              g.title,
+			Type:               "coding",
+		})
+	}
+	return resp
+}
+
+func leaders(page int) *wakatime.Leaders {
+	resp := &wakatime.Leaders{
+		CurrentUser: wakatime.Leader{
+			Rank: LeaderRank,
+			RunningTotal: wakatime.RunningTotal{
+				DailyAverage: 7200,
+				ModifiedAt:   Day,
+				TotalSeconds: 7 * 7200,
+			},
+			User: wakatime.LeaderUser{ID: "user-1", Username: "current"},
+		},
+		Data:       []wakatime.Leader{},
+		ModifiedAt: Day,
+		Page:       page,
+		Range: wakatime.LeadersRange{
+			EndDate:   Day.Format("2006-01-02"),
+			Name:      "last_7_days",
+			StartDate: Day.AddDate(0, 0, -6).Format("2006-01-02"),
+			Text:      "Last 7 Days",
+		},
+		TotalPages: LeaderPages,
+	}
+	if page <= LeaderPages {
+		resp.Data = append(resp.Data, wakatime.Leader{
+			Rank:         page,
+			RunningTotal: wakatime.RunningTotal{TotalSeconds: float64(100000 - page)},
+			User:         wakatime.LeaderUser{ID: fmt.Sprintf("leader-%d", page)},
+		})
+	}
+	return resp
+}
+
+func allTimeSinceToday() *wakatime.AllTimeSinceToday {
+	return &wakatime.AllTimeSinceToday{
+		Data: wakatime.AllTime{
+			IsUpToDate:   true,
+			Text:         "342 hrs 56 mins",
+			TotalSeconds: AllTimeSeconds,
+		},
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakeserver implements a fake Wakatime API which serves
+// deterministic synthetic data, for tests and demos.
+package fakeserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+// Prefix is the path below which the fake API is served, matching the path of
+// wakatime.DefaultBaseURI.
+const Prefix = "/api/v1/"
+
+// Server is a fake Wakatime API. It serves the summaries, goals, leaders and
+// all_time_since_today endpoints for any user. Create instances with New.
+type Server struct {
+	apiKey string
+}
+
+// New returns a Server which requires requests to authenticate with apiKey,
+// either as a Basic or Bearer token. If apiKey is empty, any request is
+// accepted.
+func New(apiKey string) *Server {
+	return &Server{apiKey: apiKey}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized.")
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, Prefix) {
+		writeError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+
+	page := 1
+	if v, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && v > 0 {
+		page = v
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == wakatime.LeadersEndpoint:
+		writeJSON(w, leaders(page))
+		return
+	case len(parts) == 3 && parts[0] == "users" && parts[1] != "":
+		switch parts[2] {
+		case wakatime.SummariesEndpoint:
+			writeJSON(w, summaries())
+			return
+		case wakatime.GoalsEndpoint:
+			writeJSON(w, goals(page))
+			return
+		case wakatime.AllTimeSinceTodayEndpoint:
+			writeJSON(w, allTimeSinceToday())
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not found.")
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.apiKey == "" {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(auth, "Basic "):
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		return err == nil && string(key) == s.apiKey
+	case strings.HasPrefix(auth, "Bearer "):
+		return strings.TrimPrefix(auth, "Bearer ") == s.apiKey
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
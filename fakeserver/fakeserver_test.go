@@ -0,0 +1,130 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeserver
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+func newTestClient(t *testing.T, apiKey string) *wakatime.Client {
+	srv := httptest.NewServer(New("secret"))
+	t.Cleanup(srv.Close)
+
+	base, err := url.Parse(srv.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wakatime.NewClient(*base, wakatime.CurrentUser, fetchBasicAuth(apiKey))
+}
+
+// fetchBasicAuth returns a wakatime.Fetcher which authenticates requests with
+// apiKey.
+func fetchBasicAuth(apiKey string) wakatime.Fetcher {
+	return func(ctx context.Context, uri url.URL, subPath string, params url.Values) (io.ReadCloser, error) {
+		uri.Path = path.Join(uri.Path, subPath)
+		uri.RawQuery = params.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(apiKey)))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, uri.Path)
+		}
+		return resp.Body, nil
+	}
+}
+
+func TestGoalsPages(t *testing.T) {
+	client := newTestClient(t, "secret")
+
+	var ids []string
+	for page := 1; ; page++ {
+		goals, err := client.Goals(context.Background(), wakatime.GoalsOptions{Page: page})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range goals.Data {
+			ids = append(ids, g.ID)
+		}
+		if page >= goals.TotalPages {
+			break
+		}
+	}
+
+	if len(ids) != len(allGoals) {
+		t.Fatalf("got goals %v, want %d goals", ids, len(allGoals))
+	}
+	for i, g := range allGoals {
+		if ids[i] != g.id {
+			t.Errorf("goal %d: got %s, want %s", i, ids[i], g.id)
+		}
+	}
+}
+
+func TestLeaders(t *testing.T) {
+	client := newTestClient(t, "secret")
+
+	leaders, err := client.Leaders(context.Background(), wakatime.LeadersOptions{Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaders.Page != 2 || leaders.TotalPages != LeaderPages || leaders.CurrentUser.Rank != LeaderRank {
+		t.Errorf("got page %d of %d with rank %d, want page 2 of %d with rank %d",
+			leaders.Page, leaders.TotalPages, leaders.CurrentUser.Rank, LeaderPages, LeaderRank)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	client := newTestClient(t, "wrong")
+
+	if _, err := client.AllTimeSinceToday(context.Background(), wakatime.AllTimeSinceTodayOptions{}); err == nil {
+		t.Error("got no error for a wrong API key")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	srv := httptest.NewServer(New(""))
+	defer srv.Close()
+
+	for _, path := range []string{"/", "/api/v1/users/current", "/api/v1/users/current/stats"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: got status %d, want %d", path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
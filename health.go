@@ -0,0 +1,114 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+)
+
+// healthyHandler reports that the process is alive.
+func healthyHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "wakatime_exporter is Healthy.")
+}
+
+// readyHandler reports whether the exporter can serve metrics from Wakatime,
+// based on the latest results of the collectors of the accounts served via
+// the metrics path. Runs for other users or credentials, e.g. by probes, are
+// ignored, so that probing an inaccessible user cannot make the exporter
+// unready. Create instances with newReadyHandler.
+type readyHandler struct {
+	// maxAge is how recently a collector must have succeeded. Zero means
+	// any success since startup will do.
+	maxAge time.Duration
+	// allowAuthErrors keeps the exporter ready even if the latest run of a
+	// collector was rejected by Wakatime because of its credentials.
+	allowAuthErrors bool
+	accounts        func() []account
+	statuses        func() []collector.CollectorStatus
+	now             func() time.Time
+}
+
+func newReadyHandler(h *handler, maxAge time.Duration, allowAuthErrors bool) *readyHandler {
+	return &readyHandler{
+		maxAge:          maxAge,
+		allowAuthErrors: allowAuthErrors,
+		accounts: func() []account {
+			accounts, _ := h.current()
+			return accounts
+		},
+		statuses: collector.Statuses,
+		now:      time.Now,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *readyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.check(); err != nil {
+		http.Error(w, fmt.Sprintf("wakatime_exporter is not ready: %s", err), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "wakatime_exporter is Ready.")
+}
+
+// check returns why the exporter is not ready, or nil if it is. Only results
+// within maxAge are considered, so that collectors which no longer run, e.g.
+// because of a reload, are eventually ignored.
+func (h *readyHandler) check() error {
+	now := h.now()
+	recent := func(t time.Time) bool {
+		return !t.IsZero() && (h.maxAge == 0 || now.Sub(t) <= h.maxAge)
+	}
+
+	served := make(map[accountKey]bool)
+	for _, a := range h.accounts() {
+		served[newAccountKey(a.inputs)] = true
+	}
+
+	succeeded := false
+	for _, s := range h.statuses() {
+		if !served[accountKey{s.Credential, s.URI}] {
+			continue
+		}
+		if !h.allowAuthErrors && recent(s.LastRun) &&
+			(s.Reason == collector.ReasonUnauthorized || s.Reason == collector.ReasonForbidden) {
+			return fmt.Errorf("collector %s was rejected by Wakatime: %s", s.Collector, s.Err)
+		}
+		if recent(s.LastSuccess) {
+			succeeded = true
+		}
+	}
+	if !succeeded {
+		if h.maxAge == 0 {
+			return fmt.Errorf("no collector has succeeded yet")
+		}
+		return fmt.Errorf("no collector has succeeded in the last %s", h.maxAge)
+	}
+	return nil
+}
+
+// accountKey identifies the collectors of an account by the credentials used
+// and the user queried, as in collector.CollectorStatus.
+type accountKey struct {
+	credential, uri string
+}
+
+func newAccountKey(in collector.CommonInputs) accountKey {
+	return accountKey{in.Credential, in.URI.String()}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+	"github.com/MacroPower/wakatime_exporter/fakeserver"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+func TestReady(t *testing.T) {
+	now := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	succeeded := collector.CollectorStatus{Collector: "goal", LastRun: now.Add(-time.Minute), LastSuccess: now.Add(-time.Minute)}
+	stale := collector.CollectorStatus{Collector: "goal", LastRun: now.Add(-2 * time.Hour), LastSuccess: now.Add(-2 * time.Hour)}
+	failed := collector.CollectorStatus{Collector: "summary", LastRun: now.Add(-time.Minute), Reason: collector.ReasonServerError}
+	rejected := collector.CollectorStatus{Collector: "summary", LastRun: now.Add(-time.Minute), Reason: collector.ReasonUnauthorized}
+	staleRejected := collector.CollectorStatus{Collector: "summary", LastRun: now.Add(-2 * time.Hour), Reason: collector.ReasonForbidden}
+	otherRejected := collector.CollectorStatus{URI: "other", Collector: "summary", LastRun: now.Add(-time.Minute), Reason: collector.ReasonForbidden}
+	otherSucceeded := collector.CollectorStatus{Credential: "other", Collector: "goal", LastRun: now.Add(-time.Minute), LastSuccess: now.Add(-time.Minute)}
+
+	tests := []struct {
+		name            string
+		statuses        []collector.CollectorStatus
+		maxAge          time.Duration
+		allowAuthErrors bool
+		want            int
+	}{
+		{"no runs", nil, time.Hour, false, http.StatusServiceUnavailable},
+		{"succeeded", []collector.CollectorStatus{succeeded, failed}, time.Hour, false, http.StatusOK},
+		{"failed", []collector.CollectorStatus{failed}, time.Hour, false, http.StatusServiceUnavailable},
+		{"stale", []collector.CollectorStatus{stale}, time.Hour, false, http.StatusServiceUnavailable},
+		{"stale without max age", []collector.CollectorStatus{stale}, 0, false, http.StatusOK},
+		{"rejected", []collector.CollectorStatus{succeeded, rejected}, time.Hour, false, http.StatusServiceUnavailable},
+		{"rejected allowed", []collector.CollectorStatus{succeeded, rejected}, time.Hour, true, http.StatusOK},
+		{"stale rejected", []collector.CollectorStatus{succeeded, staleRejected}, time.Hour, false, http.StatusOK},
+		{"other user rejected", []collector.CollectorStatus{succeeded, otherRejected}, time.Hour, false, http.StatusOK},
+		{"other credentials succeeded", []collector.CollectorStatus{otherSucceeded}, time.Hour, false, http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		h := newReadyHandler(nil, test.maxAge, test.allowAuthErrors)
+		h.accounts = func() []account { return []account{{}} }
+		h.statuses = func() []collector.CollectorStatus { return test.statuses }
+		h.now = func() time.Time { return now }
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+		if rec.Code != test.want {
+			t.Errorf("%s: got status %d, want %d: %s", test.name, rec.Code, test.want, rec.Body)
+		}
+	}
+}
+
+func TestReadyAfterProbe(t *testing.T) {
+	// The private user's stats are not accessible with the API key.
+	fake := fakeserver.New("ready-key")
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/users/private/") {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer api.Close()
+	base, err := url.Parse(api.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := collector.CommonInputs{
+		BaseURI:    *base,
+		URI:        wakatime.UserPath(*base, wakatime.CurrentUser),
+		Timeout:    5 * time.Second,
+		Retry:      collector.RetryPolicy{MaxAttempts: 1},
+		Auth:       collector.BasicAuth{APIKey: "ready-key"},
+		Credential: "ready",
+		Collectors: []string{"all-time"},
+	}
+	h, err := newHandler([]account{{name: "ready", inputs: in}}, false, false, 0, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := newReadyHandler(h, time.Hour, false)
+	ready.statuses = func() []collector.CollectorStatus {
+		// Leave out the statuses recorded by other tests.
+		var statuses []collector.CollectorStatus
+		for _, s := range collector.Statuses() {
+			if s.Credential == "ready" {
+				statuses = append(statuses, s)
+			}
+		}
+		return statuses
+	}
+	checkReady := func(when string, want int) {
+		rec := httptest.NewRecorder()
+		ready.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+		if rec.Code != want {
+			t.Errorf("%s: got status %d, want %d: %s", when, rec.Code, want, rec.Body)
+		}
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	checkReady("after a scrape", http.StatusOK)
+
+	probe := newProbeHandler(map[string]collector.CommonInputs{"": in}, 0, log.NewNopLogger())
+	rec := httptest.NewRecorder()
+	probe.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?target=private&collect[]=all-time", nil))
+	if !strings.Contains(rec.Body.String(), "wakatime_up 0") {
+		t.Fatalf("probe of an inaccessible user succeeded: %s", rec.Body)
+	}
+	checkReady("after probing an inaccessible user", http.StatusOK)
+}
@@ -16,11 +16,16 @@ limitations under the License.
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
-	"path"
+	"os/signal"
+	"syscall"
 
+	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/common/promlog/flag"
@@ -28,19 +33,23 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/MacroPower/wakatime_exporter/collector"
+	"github.com/MacroPower/wakatime_exporter/fakeserver"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+	"github.com/MacroPower/wakatime_exporter/web"
 )
 
-// UserPath appends the User path to a given URL
-func UserPath(uri *url.URL, user string) url.URL {
-	userURL := *uri
-	userPath := path.Join(userURL.Path, "users", user)
-
-	userURL.Path = userPath
-	return userURL
-}
-
 func main() {
 	var (
+		configFile = kingpin.Flag(
+			"config.file",
+			"YAML file describing the Wakatime accounts to scrape. Flags are used as defaults for any settings it omits.",
+		).Default("").Envar("WAKA_CONFIG_FILE").String()
+
+		backgroundPolling = kingpin.Flag(
+			"collector.background-polling",
+			"Run each collector in the background on its poll interval, and serve the latest results instead of querying Wakatime on every scrape.",
+		).Default("false").Envar("WAKA_COLLECTOR_BACKGROUND_POLLING").Bool()
+
 		disableDefaultCollectors = kingpin.Flag(
 			"collector.disable-defaults",
 			"Set all collectors to disabled by default.",
@@ -61,71 +70,500 @@ func main() {
 			"Exclude metrics about the exporter itself (promhttp_*, process_*, go_*).",
 		).Default("false").Envar("WAKA_DISABLE_EXPORTER_METRICS").Bool()
 
+		probePath = kingpin.Flag(
+			"web.probe-path",
+			"Path under which to expose metrics for the user given by the target parameter.",
+		).Default("/probe").Envar("WAKA_PROBE_PATH").String()
+
+		webConfigFile = kingpin.Flag(
+			"web.config.file",
+			"Path to a web config file enabling TLS and/or basic authentication for all endpoints.",
+		).Default("").Envar("WAKA_WEB_CONFIG_FILE").String()
+
+		enableLifecycle = kingpin.Flag(
+			"web.enable-lifecycle",
+			"Enable reloading the configuration via HTTP requests to /-/reload.",
+		).Default("false").Envar("WAKA_ENABLE_LIFECYCLE").Bool()
+
+		readyMaxAge = kingpin.Flag(
+			"web.ready.max-age",
+			"How recently a collector must have succeeded for /-/ready to report the exporter as ready (0 means at any time since startup).",
+		).Default("1h").Envar("WAKA_READY_MAX_AGE").Duration()
+
+		readyAllowAuthErrors = kingpin.Flag(
+			"web.ready.allow-auth-errors",
+			"Report the exporter as ready even if Wakatime recently rejected its credentials.",
+		).Default("false").Envar("WAKA_READY_ALLOW_AUTH_ERRORS").Bool()
+
+		readTimeout = kingpin.Flag(
+			"web.read-timeout",
+			"Maximum duration for reading an entire request, including its body (0 means no timeout).",
+		).Default("30s").Envar("WAKA_READ_TIMEOUT").Duration()
+
+		writeTimeout = kingpin.Flag(
+			"web.write-timeout",
+			"Maximum duration for serving a request, including any requests to Wakatime (0 means no timeout).",
+		).Default("2m").Envar("WAKA_WRITE_TIMEOUT").Duration()
+
+		idleTimeout = kingpin.Flag(
+			"web.idle-timeout",
+			"Maximum duration to keep idle keep-alive connections open (0 means the read timeout is used).",
+		).Default("2m").Envar("WAKA_IDLE_TIMEOUT").Duration()
+
+		shutdownGracePeriod = kingpin.Flag(
+			"web.shutdown-grace-period",
+			"Time given to in-flight requests to complete when shutting down on SIGTERM or SIGINT.",
+		).Default("15s").Envar("WAKA_SHUTDOWN_GRACE_PERIOD").Duration()
+
+		scrapeTimeoutOffset = kingpin.Flag(
+			"web.scrape-timeout-offset",
+			"Offset to subtract from the scrape timeout sent by Prometheus, leaving time to respond.",
+		).Default("500ms").Envar("WAKA_SCRAPE_TIMEOUT_OFFSET").Duration()
+
 		wakaScrapeURI = kingpin.Flag(
 			"wakatime.scrape-uri",
 			"Base path to query for Wakatime data.",
-		).Default("https://wakatime.com/api/v1").Envar("WAKA_SCRAPE_URI").String()
+		).Default(wakatime.DefaultBaseURI).Envar("WAKA_SCRAPE_URI").String()
 
 		wakaUser = kingpin.Flag(
 			"wakatime.user",
 			"User to query for Wakatime data.",
-		).Default("current").Envar("WAKA_USER").String()
+		).Default(wakatime.CurrentUser).Envar("WAKA_USER").String()
 
 		wakaToken = kingpin.Flag(
 			"wakatime.api-key",
 			"Token to use when getting stats from Wakatime.",
-		).Required().Envar("WAKA_API_KEY").String()
+		).Default("").Envar("WAKA_API_KEY").String()
+
+		wakaTokenFile = kingpin.Flag(
+			"wakatime.api-key-file",
+			"File containing the token to use when getting stats from Wakatime. It is read again whenever it changes.",
+		).Default("").Envar("WAKA_API_KEY_FILE").String()
+
+		wakaUseCLIConfig = kingpin.Flag(
+			"wakatime.use-cli-config",
+			"Read the API key and URL from the wakatime CLI config file ($WAKATIME_HOME/.wakatime.cfg or ~/.wakatime.cfg).",
+		).Default("false").Envar("WAKA_USE_CLI_CONFIG").Bool()
+
+		wakaCredentials = kingpin.Flag(
+			"wakatime.credential",
+			"Named credentials which probes can select with the auth parameter, as <name>=<api-key-file>. May be repeated.",
+		).PlaceHolder("NAME=FILE").Envar("WAKA_CREDENTIALS").Strings()
+
+		wakaAuthMode = kingpin.Flag(
+			"wakatime.auth-mode",
+			"How to authenticate with Wakatime, using an API key (basic) or an OAuth2 access token (oauth2).",
+		).Default("basic").Envar("WAKA_AUTH_MODE").Enum("basic", "oauth2")
+
+		wakaOAuthTokenURL = kingpin.Flag(
+			"wakatime.oauth.token-url",
+			"OAuth2 token endpoint used to refresh access tokens.",
+		).Default("https://wakatime.com/oauth/token").Envar("WAKA_OAUTH_TOKEN_URL").String()
+
+		wakaOAuthClientID = kingpin.Flag(
+			"wakatime.oauth.client-id",
+			"OAuth2 client ID used to refresh access tokens.",
+		).Default("").Envar("WAKA_OAUTH_CLIENT_ID").String()
+
+		wakaOAuthClientSecret = kingpin.Flag(
+			"wakatime.oauth.client-secret",
+			"OAuth2 client secret used to refresh access tokens.",
+		).Default("").Envar("WAKA_OAUTH_CLIENT_SECRET").String()
+
+		wakaOAuthRedirectURI = kingpin.Flag(
+			"wakatime.oauth.redirect-uri",
+			"OAuth2 redirect URI registered for the client, if required by the token endpoint.",
+		).Default("").Envar("WAKA_OAUTH_REDIRECT_URI").String()
+
+		wakaOAuthTokenFile = kingpin.Flag(
+			"wakatime.oauth.token-file",
+			"JSON file holding the OAuth2 access and refresh tokens, which is updated whenever the token is refreshed.",
+		).Default("").Envar("WAKA_OAUTH_TOKEN_FILE").String()
 
 		wakaTimeout = kingpin.Flag(
 			"wakatime.timeout",
-			"Timeout for trying to get stats from Wakatime.",
+			"Timeout for trying to get stats from Wakatime, including any retries.",
 		).Default("5s").Envar("WAKA_TIMEOUT").Duration()
 
 		wakaSSLVerify = kingpin.Flag(
 			"wakatime.ssl-verify",
 			"Flag that enables SSL certificate verification for the scrape URI.",
 		).Default("true").Envar("WAKA_SSL_VERIFY").Bool()
+
+		wakaMaxBodySize = kingpin.Flag(
+			"wakatime.max-body-size",
+			"Maximum size of a response body read from Wakatime (0 means no limit).",
+		).Default("10MB").Envar("WAKA_MAX_BODY_SIZE").Bytes()
+
+		wakaStrictDecode = kingpin.Flag(
+			"wakatime.strict-decode",
+			"Report fields in Wakatime responses which are unknown, missing or of an unexpected type.",
+		).Default("false").Envar("WAKA_STRICT_DECODE").Bool()
+
+		wakaRecordDir = kingpin.Flag(
+			"wakatime.record-dir",
+			"Directory in which to save every response from Wakatime, for replaying later.",
+		).Default("").Envar("WAKA_RECORD_DIR").String()
+
+		wakaReplayDir = kingpin.Flag(
+			"wakatime.replay-dir",
+			"Directory of responses saved via --wakatime.record-dir to serve instead of querying Wakatime.",
+		).Default("").Envar("WAKA_REPLAY_DIR").String()
+
+		wakaProxyURL = kingpin.Flag(
+			"wakatime.proxy-url",
+			"Proxy to send requests to Wakatime through (default: taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY).",
+		).Default("").Envar("WAKA_PROXY_URL").String()
+
+		wakaCAFile = kingpin.Flag(
+			"wakatime.tls.ca-file",
+			"CA certificate bundle used to verify the scrape URI, instead of the system roots.",
+		).Default("").Envar("WAKA_TLS_CA_FILE").String()
+
+		wakaCertFile = kingpin.Flag(
+			"wakatime.tls.cert-file",
+			"Client certificate file to present to the scrape URI.",
+		).Default("").Envar("WAKA_TLS_CERT_FILE").String()
+
+		wakaKeyFile = kingpin.Flag(
+			"wakatime.tls.key-file",
+			"Client certificate key file to present to the scrape URI.",
+		).Default("").Envar("WAKA_TLS_KEY_FILE").String()
+
+		wakaMinTLSVersion = kingpin.Flag(
+			"wakatime.tls.min-version",
+			"Minimum TLS version accepted from the scrape URI.",
+		).Default("TLS12").Envar("WAKA_TLS_MIN_VERSION").Enum("TLS10", "TLS11", "TLS12", "TLS13")
+
+		wakaMaxIdleConns = kingpin.Flag(
+			"wakatime.max-idle-conns",
+			"Maximum number of idle connections kept open to Wakatime.",
+		).Default("100").Envar("WAKA_MAX_IDLE_CONNS").Int()
+
+		wakaMaxIdleConnsPerHost = kingpin.Flag(
+			"wakatime.max-idle-conns-per-host",
+			"Maximum number of idle connections kept open to each Wakatime host.",
+		).Default("4").Envar("WAKA_MAX_IDLE_CONNS_PER_HOST").Int()
+
+		wakaMaxConnsPerHost = kingpin.Flag(
+			"wakatime.max-conns-per-host",
+			"Maximum number of connections to each Wakatime host (0 means no limit).",
+		).Default("0").Envar("WAKA_MAX_CONNS_PER_HOST").Int()
+
+		wakaRetryMaxAttempts = kingpin.Flag(
+			"wakatime.retry.max-attempts",
+			"Maximum number of attempts for each request to Wakatime, including the first.",
+		).Default("3").Envar("WAKA_RETRY_MAX_ATTEMPTS").Int()
+
+		wakaRetryInitialBackoff = kingpin.Flag(
+			"wakatime.retry.initial-backoff",
+			"Backoff before the first retry of a failed request to Wakatime.",
+		).Default("500ms").Envar("WAKA_RETRY_INITIAL_BACKOFF").Duration()
+
+		wakaRetryMaxBackoff = kingpin.Flag(
+			"wakatime.retry.max-backoff",
+			"Maximum backoff between retries of a failed request to Wakatime.",
+		).Default("5s").Envar("WAKA_RETRY_MAX_BACKOFF").Duration()
+
+		wakaRateLimit = kingpin.Flag(
+			"wakatime.rate-limit.requests-per-second",
+			"Maximum average number of requests per second sent to Wakatime (0 disables the limit).",
+		).Default("10").Envar("WAKA_RATE_LIMIT_REQUESTS_PER_SECOND").Float64()
+
+		wakaRateLimitBurst = kingpin.Flag(
+			"wakatime.rate-limit.burst",
+			"Maximum number of requests sent to Wakatime in a single burst.",
+		).Default("10").Envar("WAKA_RATE_LIMIT_BURST").Int()
+
+		wakaRateLimitDaily = kingpin.Flag(
+			"wakatime.rate-limit.requests-per-day",
+			"Maximum number of requests sent to Wakatime within any 24 hours (0 disables the limit).",
+		).Default("0").Envar("WAKA_RATE_LIMIT_REQUESTS_PER_DAY").Int()
+	)
+
+	kingpin.Command("serve", "Run the exporter.").Default()
+
+	var (
+		fakeServerCmd = kingpin.Command(
+			"fake-server",
+			"Run a fake Wakatime API serving synthetic data, for tests and demos.",
+		)
+
+		fakeServerListenAddress = fakeServerCmd.Flag(
+			"listen-address",
+			"Address on which to serve the fake Wakatime API.",
+		).Default(":9213").Envar("WAKA_FAKE_SERVER_LISTEN_ADDRESS").String()
+
+		fakeServerAPIKey = fakeServerCmd.Flag(
+			"api-key",
+			"API key which requests to the fake Wakatime API must use (default: any).",
+		).Default("").Envar("WAKA_FAKE_SERVER_API_KEY").String()
 	)
 
 	promlogConfig := &promlog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promlogConfig)
 	kingpin.Version(version.Print("wakatime_exporter"))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	cmd := kingpin.Parse()
 	logger := promlog.New(promlogConfig)
 
+	if cmd == fakeServerCmd.FullCommand() {
+		level.Info(logger).Log("msg", "Serving fake Wakatime API", "address", *fakeServerListenAddress, "path", fakeserver.Prefix)
+		if err := http.ListenAndServe(*fakeServerListenAddress, fakeserver.New(*fakeServerAPIKey)); err != nil {
+			level.Error(logger).Log("msg", "Error starting HTTP server", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *disableDefaultCollectors {
 		collector.DisableDefaultCollectors()
 	}
 	level.Info(logger).Log("msg", "Starting wakatime_exporter", "version", version.Info())
 	level.Info(logger).Log("msg", "Build context", "build_context", version.BuildContext())
 
+	var cliConfigPath string
+	if *wakaUseCLIConfig {
+		var err error
+		cliConfigPath, err = collector.DefaultCLIConfigPath()
+		if err != nil {
+			level.Error(logger).Log("msg", "Error locating wakatime CLI config", "err", err)
+			os.Exit(1)
+		}
+		data, err := ioutil.ReadFile(cliConfigPath)
+		if err != nil {
+			level.Error(logger).Log("msg", "Error reading wakatime CLI config", "err", err)
+			os.Exit(1)
+		}
+		// The CLI's API URL is only used if the scrape URI was not changed.
+		if cliConfig := collector.ParseCLIConfig(data); cliConfig.APIURL != "" && *wakaScrapeURI == wakatime.DefaultBaseURI {
+			*wakaScrapeURI = cliConfig.APIURL
+		}
+	}
+
+	if *wakaRecordDir != "" && *wakaReplayDir != "" {
+		level.Error(logger).Log("msg", "Only one of --wakatime.record-dir and --wakatime.replay-dir can be set")
+		os.Exit(1)
+	}
+	if *wakaRecordDir != "" {
+		if err := os.MkdirAll(*wakaRecordDir, 0700); err != nil {
+			level.Error(logger).Log("msg", "Error creating record directory", "err", err)
+			os.Exit(1)
+		}
+	}
+
 	wakaBaseURI, err := url.Parse(*wakaScrapeURI)
 	if err != nil {
 		level.Error(logger).Log("msg", "Error parsing URL", "err", err)
 		os.Exit(1)
 	}
 
-	http.Handle(*metricsPath, newHandler(collector.CommonInputs{
+	wakaTransport, err := collector.NewTransport(collector.TransportConfig{
+		ProxyURL:            *wakaProxyURL,
+		CAFile:              *wakaCAFile,
+		CertFile:            *wakaCertFile,
+		KeyFile:             *wakaKeyFile,
+		MinTLSVersion:       *wakaMinTLSVersion,
+		SSLVerify:           *wakaSSLVerify,
+		MaxIdleConns:        *wakaMaxIdleConns,
+		MaxIdleConnsPerHost: *wakaMaxIdleConnsPerHost,
+		MaxConnsPerHost:     *wakaMaxConnsPerHost,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "Error creating HTTP transport", "err", err)
+		os.Exit(1)
+	}
+
+	var (
+		wakaAuth collector.Authorizer
+		// wakaAuthErr is set if no credentials were given via flags, which
+		// is only an error if an account needs them.
+		wakaAuthErr error
+	)
+	switch *wakaAuthMode {
+	case "basic":
+		switch {
+		case *wakaToken != "":
+			wakaAuth = collector.BasicAuth{APIKey: *wakaToken}
+		case *wakaTokenFile != "":
+			wakaAuth, err = collector.NewAPIKeyFile(*wakaTokenFile)
+		case *wakaUseCLIConfig:
+			wakaAuth, err = collector.NewCLIConfigAPIKey(cliConfigPath)
+		case *wakaReplayDir != "":
+			// Replayed responses need no credentials.
+		default:
+			wakaAuthErr = errors.New("set --wakatime.api-key, --wakatime.api-key-file or --wakatime.use-cli-config")
+		}
+		if err == nil && *configFile == "" {
+			err = wakaAuthErr
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "Error loading API key", "err", err)
+			os.Exit(1)
+		}
+	case "oauth2":
+		wakaAuth, err = collector.NewOAuth(collector.OAuthConfig{
+			TokenURL:     *wakaOAuthTokenURL,
+			ClientID:     *wakaOAuthClientID,
+			ClientSecret: *wakaOAuthClientSecret,
+			RedirectURI:  *wakaOAuthRedirectURI,
+			TokenFile:    *wakaOAuthTokenFile,
+		}, wakaTransport, log.With(logger, "component", "oauth"))
+		if err != nil {
+			level.Error(logger).Log("msg", "Error loading OAuth token", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	commonInputs := collector.CommonInputs{
 		BaseURI:   *wakaBaseURI,
-		URI:       UserPath(wakaBaseURI, *wakaUser),
+		URI:       wakatime.UserPath(*wakaBaseURI, *wakaUser),
 		Token:     *wakaToken,
 		SSLVerify: *wakaSSLVerify,
 		Timeout:   *wakaTimeout,
-	}, !*disableExporterMetrics, logger))
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(`<html>
-			<head><title>Wakatime Exporter</title></head>
-			<body>
-			<h1>Wakatime Exporter</h1>
-			<p><a href="` + *metricsPath + `">Metrics</a></p>
-			</body>
-			</html>`))
-	})
+		Retry: collector.RetryPolicy{
+			MaxAttempts:    *wakaRetryMaxAttempts,
+			InitialBackoff: *wakaRetryInitialBackoff,
+			MaxBackoff:     *wakaRetryMaxBackoff,
+		},
+		Cache:        collector.NewResponseCache(),
+		RateLimiter:  collector.NewRateLimiter("", *wakaRateLimit, *wakaRateLimitBurst, *wakaRateLimitDaily),
+		Transport:    wakaTransport,
+		Auth:         wakaAuth,
+		MaxBodySize:  int64(*wakaMaxBodySize),
+		RecordDir:    *wakaRecordDir,
+		ReplayDir:    *wakaReplayDir,
+		StrictDecode: *wakaStrictDecode,
+	}
+
+	newRateLimiter := func(credential string) *collector.RateLimiter {
+		return collector.NewRateLimiter(credential, *wakaRateLimit, *wakaRateLimitBurst, *wakaRateLimitDaily)
+	}
+
+	// Named credentials are given via flags, so they are kept across
+	// reloads. Their API key files are read again whenever they change.
+	credentialInputs := make(map[string]collector.CommonInputs)
+	for _, credential := range *wakaCredentials {
+		name, path, err := parseCredential(credential)
+		if err != nil {
+			level.Error(logger).Log("msg", "Error parsing credentials", "err", err)
+			os.Exit(1)
+		}
+		if _, ok := credentialInputs[name]; ok {
+			level.Error(logger).Log("msg", "Duplicate credentials", "name", name)
+			os.Exit(1)
+		}
+		auth, err := collector.NewAPIKeyFile(path)
+		if err != nil {
+			level.Error(logger).Log("msg", "Error loading API key", "name", name, "err", err)
+			os.Exit(1)
+		}
+
+		in := accountInputs(commonInputs, name, newRateLimiter)
+		in.Auth = auth
+		credentialInputs[name] = in
+	}
+
+	// rateLimiters holds the rate limiter of each account in the config
+	// file, so that reloads don't reset the requests remaining.
+	rateLimiters := make(map[string]*collector.RateLimiter)
+
+	// loadAccounts reads the config file, if any, and returns the accounts
+	// to serve via the metrics path and the inputs for each set of
+	// credentials probes can select. It is called upon startup and reloads.
+	loadAccounts := func() ([]account, map[string]collector.CommonInputs, error) {
+		accounts := []account{{inputs: commonInputs}}
+		if *configFile != "" {
+			cfg, err := loadConfig(*configFile)
+			if err != nil {
+				return nil, nil, err
+			}
+			accounts, err = newAccounts(cfg, commonInputs, *wakaUser, wakaAuthErr, newRateLimiter)
+			if err != nil {
+				return nil, nil, err
+			}
+			for i, a := range accounts {
+				if l, ok := rateLimiters[a.name]; ok {
+					accounts[i].inputs.RateLimiter = l
+				} else {
+					rateLimiters[a.name] = a.inputs.RateLimiter
+				}
+			}
+		}
+
+		// Probes use the first account unless another account or named
+		// credentials are selected.
+		probeInputs := map[string]collector.CommonInputs{"": accounts[0].inputs}
+		for _, a := range accounts {
+			if a.name != "" {
+				probeInputs[a.name] = a.inputs
+			}
+		}
+		for name, in := range credentialInputs {
+			if _, ok := probeInputs[name]; ok {
+				return nil, nil, fmt.Errorf("duplicate credentials %s", name)
+			}
+			probeInputs[name] = in
+		}
+		return accounts, probeInputs, nil
+	}
+
+	accounts, probeInputs, err := loadAccounts()
+	if err != nil {
+		level.Error(logger).Log("msg", "Error loading config file", "err", err)
+		os.Exit(1)
+	}
+	metricsHandler, err := newHandler(accounts, !*disableExporterMetrics, *backgroundPolling, *scrapeTimeoutOffset, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "Couldn't create metrics handler", "err", err)
+		os.Exit(1)
+	}
+	probeHandler := newProbeHandler(probeInputs, *scrapeTimeoutOffset, log.With(logger, "handler", "probe"))
+	reloader := newReloader(loadAccounts, metricsHandler, probeHandler, logger)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloader.reload()
+		}
+	}()
+
+	http.Handle(*metricsPath, metricsHandler)
+	http.Handle(*probePath, probeHandler)
+	http.HandleFunc("/-/healthy", healthyHandler)
+	collector.SetStatusMaxAge(*readyMaxAge)
+	http.Handle("/-/ready", newReadyHandler(metricsHandler, *readyMaxAge, *readyAllowAuthErrors))
+	if *enableLifecycle {
+		http.Handle("/-/reload", reloader)
+	}
+	http.Handle("/", newStatusHandler(metricsHandler, sanitizedFlags(kingpin.CommandLine), []link{
+		{Name: "Metrics", Path: *metricsPath},
+		{Name: "Probe current user", Path: *probePath + "?target=current"},
+		{Name: "Health", Path: "/-/healthy"},
+		{Name: "Readiness", Path: "/-/ready"},
+	}, log.With(logger, "handler", "status")))
+
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
 
 	level.Info(logger).Log("msg", "Listening on", "address", *listenAddress)
-	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
-		level.Error(logger).Log("msg", "Error starting HTTP server", "err", err)
+	server := &http.Server{
+		Addr:         *listenAddress,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+	err = runServer(server, func() error {
+		return web.ListenAndServe(server, *webConfigFile, logger)
+	}, term, *shutdownGracePeriod, logger)
+
+	// Reloads would start polling again.
+	signal.Stop(hup)
+	metricsHandler.stop()
+	if err != nil {
+		level.Error(logger).Log("msg", "Error running HTTP server", "err", err)
 		os.Exit(1)
 	}
+	level.Info(logger).Log("msg", "Stopped wakatime_exporter")
 }
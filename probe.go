@@ -0,0 +1,115 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+// probeHandler serves the metrics of the user given by the target parameter,
+// using the named credentials given by the auth parameter, so that a single
+// exporter can serve several users. Create instances with newProbeHandler.
+type probeHandler struct {
+	// mtx protects inputs, which are replaced when the accounts are
+	// reloaded.
+	mtx sync.RWMutex
+	// inputs holds the inputs for each set of credentials by name. The
+	// default credentials have an empty name.
+	inputs        map[string]collector.CommonInputs
+	timeoutOffset time.Duration
+	logger        log.Logger
+}
+
+func newProbeHandler(inputs map[string]collector.CommonInputs, timeoutOffset time.Duration, logger log.Logger) *probeHandler {
+	return &probeHandler{
+		inputs:        inputs,
+		timeoutOffset: timeoutOffset,
+		logger:        logger,
+	}
+}
+
+// setInputs replaces the inputs for each set of credentials.
+func (h *probeHandler) setInputs(inputs map[string]collector.CommonInputs) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.inputs = inputs
+}
+
+// ServeHTTP implements http.Handler.
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	// The target is joined to the API path, so it must not be able to
+	// select another endpoint.
+	if strings.Contains(target, "/") || strings.Contains(target, "..") || target == "." {
+		http.Error(w, fmt.Sprintf("Invalid target %q", target), http.StatusBadRequest)
+		return
+	}
+	auth := params.Get("auth")
+	h.mtx.RLock()
+	in, ok := h.inputs[auth]
+	h.mtx.RUnlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown credentials %q", auth), http.StatusBadRequest)
+		return
+	}
+	uri := wakatime.UserPath(in.BaseURI, target)
+	in.OtherUser = target != wakatime.CurrentUser && uri.String() != in.URI.String()
+	in.URI = uri
+
+	logger := log.With(h.logger, "target", target, "auth", auth)
+	level.Debug(logger).Log("msg", "Probing Wakatime user", "filters", strings.Join(params["collect[]"], ","))
+
+	nc, err := collector.NewWakaCollector(in, logger, params["collect[]"]...)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Couldn't create probe collector", "err", err)
+		http.Error(w, fmt.Sprintf("Couldn't create probe collector: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := scrapeContext(r, h.timeoutOffset, h.logger)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(nc.WithContext(ctx))
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}).ServeHTTP(w, r)
+}
+
+// parseCredential parses a named credential flag of the form
+// <name>=<api-key-file>.
+func parseCredential(s string) (name, path string, err error) {
+	i := strings.Index(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return "", "", fmt.Errorf("invalid credential %q, expected <name>=<api-key-file>", s)
+	}
+	return s[:i], s[i+1:], nil
+}
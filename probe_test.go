@@ -0,0 +1,117 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+	"github.com/MacroPower/wakatime_exporter/fakeserver"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+func TestMain(m *testing.M) {
+	// Apply the defaults of the collector flags.
+	if _, err := kingpin.CommandLine.Parse(nil); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestProbe(t *testing.T) {
+	api := httptest.NewServer(fakeserver.New("team-key"))
+	defer api.Close()
+	base, err := url.Parse(api.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := collector.CommonInputs{
+		BaseURI: *base,
+		Token:   "wrong",
+		Timeout: 5 * time.Second,
+		Retry:   collector.RetryPolicy{MaxAttempts: 1},
+	}
+	team := in
+	team.Credential = "team"
+	team.Token = "team-key"
+	bob := team
+	bob.Credential = "bob"
+	bob.URI = wakatime.UserPath(*base, "bob")
+	srv := httptest.NewServer(newProbeHandler(map[string]collector.CommonInputs{"": in, "team": team, "bob": bob}, 0, log.NewNopLogger()))
+	defer srv.Close()
+
+	tests := []struct {
+		query   string
+		code    int
+		want    string
+		notWant string
+	}{
+		{query: "target=alice&auth=team", code: http.StatusOK, want: "wakatime_up 1", notWant: "wakatime_leaderboard_rank"},
+		{query: "target=alice", code: http.StatusOK, want: "wakatime_up 0"},
+		{query: "target=current&auth=team&collect[]=leader", code: http.StatusOK, want: "wakatime_leaderboard_rank 42"},
+		{query: "target=bob&auth=bob", code: http.StatusOK, want: "wakatime_leaderboard_rank 42"},
+		{query: "target=alice&auth=bob&collect[]=leader", code: http.StatusBadRequest, want: "collector leader only reports on the user owning the credentials"},
+		{query: "auth=team", code: http.StatusBadRequest, want: "Target parameter is missing"},
+		{query: "target=../leaders&auth=team", code: http.StatusBadRequest, want: `Invalid target "../leaders"`},
+		{query: "target=alice%2Fgoals&auth=team", code: http.StatusBadRequest, want: `Invalid target "alice/goals"`},
+		{query: "target=..&auth=team", code: http.StatusBadRequest, want: `Invalid target ".."`},
+		{query: "target=.&auth=team", code: http.StatusBadRequest, want: `Invalid target "."`},
+		{query: "target=alice&auth=other", code: http.StatusBadRequest, want: `Unknown credentials "other"`},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + "/probe?" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s: got status %d, want %d", tt.query, resp.StatusCode, tt.code)
+		}
+		if !strings.Contains(string(body), tt.want) {
+			t.Errorf("%s: got body %q, want it to contain %q", tt.query, body, tt.want)
+		}
+		if tt.notWant != "" && strings.Contains(string(body), tt.notWant) {
+			t.Errorf("%s: got body %q, want it not to contain %q", tt.query, body, tt.notWant)
+		}
+	}
+}
+
+func TestParseCredential(t *testing.T) {
+	name, path, err := parseCredential("team=/etc/wakatime/team.key")
+	if err != nil || name != "team" || path != "/etc/wakatime/team.key" {
+		t.Errorf("got %q, %q, %v", name, path, err)
+	}
+	for _, s := range []string{"team", "=file", "team="} {
+		if _, _, err := parseCredential(s); err == nil {
+			t.Errorf("%s: got no error", s)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+)
+
+var (
+	configLastReloadSuccessful = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "wakatime",
+			Subsystem: "exporter",
+			Name:      "config_last_reload_successful",
+			Help:      "wakatime_exporter: Whether the last configuration reload attempt was successful.",
+		},
+	)
+	configLastReloadSuccessTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "wakatime",
+			Subsystem: "exporter",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "wakatime_exporter: Timestamp of the last successful configuration reload.",
+		},
+	)
+
+	// reloadMetrics are served alongside the metrics of every account.
+	reloadMetrics = []prometheus.Collector{configLastReloadSuccessful, configLastReloadSuccessTimestamp}
+)
+
+// reloader rebuilds the accounts served by the metrics and probe handlers.
+// Create instances with newReloader.
+type reloader struct {
+	// mtx serializes reloads.
+	mtx sync.Mutex
+	// load reads the configuration, returning the accounts to serve via the
+	// metrics path and the inputs for each set of credentials probes can
+	// select.
+	load    func() ([]account, map[string]collector.CommonInputs, error)
+	handler *handler
+	probe   *probeHandler
+	logger  log.Logger
+}
+
+func newReloader(load func() ([]account, map[string]collector.CommonInputs, error), h *handler, probe *probeHandler, logger log.Logger) *reloader {
+	configLastReloadSuccessful.Set(1)
+	configLastReloadSuccessTimestamp.SetToCurrentTime()
+	return &reloader{
+		load:    load,
+		handler: h,
+		probe:   probe,
+		logger:  logger,
+	}
+}
+
+// reload reads the configuration again and replaces the accounts of each
+// handler. If anything fails, the handlers keep their previous accounts.
+func (r *reloader) reload() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	level.Info(r.logger).Log("msg", "Reloading configuration")
+	err := r.apply()
+	if err != nil {
+		level.Error(r.logger).Log("msg", "Error reloading configuration", "err", err)
+		configLastReloadSuccessful.Set(0)
+		return err
+	}
+	level.Info(r.logger).Log("msg", "Completed reloading configuration")
+	configLastReloadSuccessful.Set(1)
+	configLastReloadSuccessTimestamp.SetToCurrentTime()
+	return nil
+}
+
+func (r *reloader) apply() error {
+	accounts, probeInputs, err := r.load()
+	if err != nil {
+		return err
+	}
+	if err := r.handler.setAccounts(accounts); err != nil {
+		return err
+	}
+	r.probe.setInputs(probeInputs)
+	return nil
+}
+
+// ServeHTTP implements http.Handler, reloading the configuration on POST
+// requests.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.reload(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload configuration: %s", err), http.StatusInternalServerError)
+	}
+}
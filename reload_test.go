@@ -0,0 +1,140 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+	"github.com/MacroPower/wakatime_exporter/fakeserver"
+	"github.com/MacroPower/wakatime_exporter/wakatime"
+)
+
+func TestReload(t *testing.T) {
+	api := httptest.NewServer(fakeserver.New("secret"))
+	defer api.Close()
+	base, err := url.Parse(api.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := collector.CommonInputs{
+		BaseURI: *base,
+		Timeout: 5 * time.Second,
+		Retry:   collector.RetryPolicy{MaxAttempts: 1},
+		Auth:    collector.BasicAuth{APIKey: "secret"},
+	}
+
+	path := writeConfig(t, `accounts: [{name: alice, collectors: [leader]}]`)
+	load := func() ([]account, map[string]collector.CommonInputs, error) {
+		cfg, err := loadConfig(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		accounts, err := newAccounts(cfg, defaults, wakatime.CurrentUser, nil, func(string) *collector.RateLimiter { return nil })
+		if err != nil {
+			return nil, nil, err
+		}
+		return accounts, map[string]collector.CommonInputs{"": accounts[0].inputs}, nil
+	}
+	accounts, probeInputs, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := newHandler(accounts, false, false, 0, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newReloader(load, h, newProbeHandler(probeInputs, 0, log.NewNopLogger()), log.NewNopLogger())
+
+	metrics := httptest.NewServer(h)
+	defer metrics.Close()
+	reload := httptest.NewServer(r)
+	defer reload.Close()
+	scrape := func() string {
+		resp, err := http.Get(metrics.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	post := func() int {
+		resp, err := http.Post(reload.URL, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if body := scrape(); strings.Contains(body, "wakatime_cumulative_seconds_total") {
+		t.Fatalf("got all-time metrics before enabling the collector:\n%s", body)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`accounts: [{name: alice, collectors: [leader, all-time]}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("got status %d reloading a valid config", code)
+	}
+	body := scrape()
+	for _, want := range []string{
+		"wakatime_cumulative_seconds_total 1.234567e+06",
+		"wakatime_exporter_config_last_reload_successful 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got metrics without %s after reloading:\n%s", want, body)
+		}
+	}
+
+	// An invalid config is reported, and the previous accounts are kept.
+	if err := ioutil.WriteFile(path, []byte(`accounts: [{name: alice, collectors: [bogus]}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code := post(); code != http.StatusInternalServerError {
+		t.Errorf("got status %d reloading an invalid config", code)
+	}
+	body = scrape()
+	for _, want := range []string{
+		"wakatime_cumulative_seconds_total 1.234567e+06",
+		"wakatime_exporter_config_last_reload_successful 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got metrics without %s after a failed reload:\n%s", want, body)
+		}
+	}
+
+	resp, err := http.Get(reload.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d for a GET request", resp.StatusCode)
+	}
+}
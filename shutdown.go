@@ -0,0 +1,65 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// runServer runs serve, which serves server, until a signal is received on
+// term. Requests in flight are then given gracePeriod to complete before
+// their connections are closed.
+func runServer(server *http.Server, serve func() error, term <-chan os.Signal, gracePeriod time.Duration, logger log.Logger) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serve()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-term:
+		level.Info(logger).Log("msg", "Received signal, draining in-flight requests", "signal", sig, "grace_period", gracePeriod)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	go func() {
+		// A second signal skips the rest of the grace period.
+		select {
+		case <-term:
+			level.Warn(logger).Log("msg", "Received second signal, closing connections")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return fmt.Errorf("couldn't drain in-flight requests: %s", err)
+	}
+	if err := <-errc; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// startServer runs a server whose requests block until release is closed,
+// and returns its address and the result of runServer.
+func startServer(t *testing.T, term chan os.Signal, gracePeriod time.Duration, started chan<- struct{}, release <-chan struct{}) (string, <-chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- runServer(server, func() error { return server.Serve(l) }, term, gracePeriod, log.NewNopLogger())
+	}()
+	return l.Addr().String(), errc
+}
+
+func TestRunServerDrains(t *testing.T) {
+	term := make(chan os.Signal, 1)
+	started, release := make(chan struct{}), make(chan struct{})
+	addr, errc := startServer(t, term, 5*time.Second, started, release)
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	term <- syscall.SIGTERM
+	select {
+	case err := <-errc:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-body; got != "done" {
+		t.Errorf("got response %q", got)
+	}
+	if err := <-errc; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunServerGracePeriod(t *testing.T) {
+	term := make(chan os.Signal, 1)
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	addr, errc := startServer(t, term, 100*time.Millisecond, started, release)
+
+	go http.Get("http://" + addr)
+	<-started
+
+	term <- syscall.SIGTERM
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("got no error for requests still in flight after the grace period")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after the grace period")
+	}
+}
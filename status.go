@@ -0,0 +1,281 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/version"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/MacroPower/wakatime_exporter/collector"
+)
+
+// secretFlags are the flags whose values are never shown.
+var secretFlags = map[string]bool{
+	"wakatime.api-key":             true,
+	"wakatime.oauth.client-secret": true,
+}
+
+const secretValue = "<secret>"
+
+// link is a link shown on the status page.
+type link struct {
+	Name, Path string
+}
+
+// flagValue is the effective value of a flag.
+type flagValue struct {
+	Name, Value string
+}
+
+// sanitizedFlags returns the values of the flags of app, without secrets.
+func sanitizedFlags(app *kingpin.Application) []flagValue {
+	var flags []flagValue
+	for _, f := range app.Model().Flags {
+		if f.Hidden || f.Name == "help" || f.Name == "version" {
+			continue
+		}
+		value := f.String()
+		if secretFlags[f.Name] && value != "" {
+			value = secretValue
+		}
+		flags = append(flags, flagValue{Name: f.Name, Value: redactURL(value)})
+	}
+	return flags
+}
+
+// redactURL replaces the password in s, if it is a URL with one.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		return u.String()
+	}
+	return s
+}
+
+// statusHandler serves a landing page describing the exporter's
+// configuration and the latest results of each collector. Create instances
+// with newStatusHandler.
+type statusHandler struct {
+	handler *handler
+	flags   []flagValue
+	links   []link
+	logger  log.Logger
+	// statuses returns the status of each collector which has run.
+	statuses func() []collector.CollectorStatus
+}
+
+func newStatusHandler(h *handler, flags []flagValue, links []link, logger log.Logger) *statusHandler {
+	return &statusHandler{
+		handler:  h,
+		flags:    flags,
+		links:    links,
+		logger:   logger,
+		statuses: collector.Statuses,
+	}
+}
+
+// statusPage is the data rendered by statusTemplate.
+type statusPage struct {
+	Version  string
+	Now      string
+	Links    []link
+	Accounts []accountStatus
+	// Others holds the collectors run for users or using credentials other
+	// than those of the accounts, e.g. by probes.
+	Others []collectorStatus
+	Flags  []flagValue
+}
+
+type accountStatus struct {
+	Name             string
+	URI              string
+	Labels           string
+	CollectorOptions string
+	Collectors       []collectorStatus
+}
+
+type collectorStatus struct {
+	Credential  string
+	URI         string
+	Name        string
+	Enabled     bool
+	Endpoints   string
+	LastRun     string
+	LastSuccess string
+	Duration    string
+	Err         string
+}
+
+// ServeHTTP implements http.Handler.
+func (h *statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	now := time.Now()
+	all := h.statuses()
+	statuses := make(map[accountKey]map[string]collector.CollectorStatus)
+	for _, s := range all {
+		key := accountKey{s.Credential, s.URI}
+		if statuses[key] == nil {
+			statuses[key] = make(map[string]collector.CollectorStatus)
+		}
+		statuses[key][s.Collector] = s
+	}
+
+	page := statusPage{
+		Version: version.Info(),
+		Now:     now.UTC().Format(time.RFC3339),
+		Links:   h.links,
+		Flags:   h.flags,
+	}
+	accounts, ncs := h.handler.current()
+	for i, a := range accounts {
+		as := accountStatus{
+			Name:             a.name,
+			URI:              redactURL(a.inputs.URI.String()),
+			Labels:           formatLabels(a.labels),
+			CollectorOptions: formatOptions(a.inputs.CollectorOptions),
+		}
+		if as.Name == "" {
+			as.Name = "default"
+		}
+		for _, name := range collector.Names() {
+			_, enabled := ncs[i].Collectors[name]
+			s, ran := statuses[newAccountKey(a.inputs)][name]
+			if !enabled && !ran {
+				continue
+			}
+			cs := newCollectorStatus(s, now)
+			cs.Name = name
+			cs.Enabled = enabled
+			var endpoints []string
+			for _, uri := range a.inputs.Endpoints(name) {
+				endpoints = append(endpoints, uri.Path)
+			}
+			cs.Endpoints = strings.Join(endpoints, ", ")
+			as.Collectors = append(as.Collectors, cs)
+		}
+		delete(statuses, newAccountKey(a.inputs))
+		page.Accounts = append(page.Accounts, as)
+	}
+	for _, s := range all {
+		if _, ok := statuses[accountKey{s.Credential, s.URI}]; ok {
+			cs := newCollectorStatus(s, now)
+			cs.Credential = s.Credential
+			cs.URI = redactURL(s.URI)
+			cs.Name = s.Collector
+			page.Others = append(page.Others, cs)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, page); err != nil {
+		level.Error(h.logger).Log("msg", "Error rendering status page", "err", err)
+	}
+}
+
+func newCollectorStatus(s collector.CollectorStatus, now time.Time) collectorStatus {
+	cs := collectorStatus{Err: s.Err}
+	if !s.LastRun.IsZero() {
+		cs.LastRun = formatTime(s.LastRun, now)
+		cs.Duration = s.LastDuration.Round(time.Millisecond).String()
+	}
+	if !s.LastSuccess.IsZero() {
+		cs.LastSuccess = formatTime(s.LastSuccess, now)
+	}
+	return cs
+}
+
+func formatTime(t, now time.Time) string {
+	return fmt.Sprintf("%s (%s ago)", t.UTC().Format(time.RFC3339), now.Sub(t).Round(time.Second))
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+func formatOptions(options map[string]map[string]string) string {
+	var pairs []string
+	for c, o := range options {
+		for name, value := range o {
+			pairs = append(pairs, fmt.Sprintf("%s.%s=%s", c, name, value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<html>
+<head>
+<title>Wakatime Exporter</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+.failed { color: #a00; }
+</style>
+</head>
+<body>
+<h1>Wakatime Exporter</h1>
+<p>{{range .Links}}<a href="{{.Path}}">{{.Name}}</a> {{end}}</p>
+<p>Version: {{.Version}}<br>Time: {{.Now}}</p>
+
+<h2>Collectors</h2>
+{{range .Accounts}}
+<h3>Account {{.Name}}</h3>
+<p>Wakatime URI: {{.URI}}{{if .Labels}}<br>Labels: {{.Labels}}{{end}}{{if .CollectorOptions}}<br>Collector options: {{.CollectorOptions}}{{end}}</p>
+<table>
+<tr><th>Collector</th><th>Enabled</th><th>Endpoints</th><th>Last run</th><th>Duration</th><th>Last success</th><th>Last error</th></tr>
+{{range .Collectors}}<tr><td>{{.Name}}</td><td>{{.Enabled}}</td><td>{{.Endpoints}}</td><td>{{or .LastRun "never"}}</td><td>{{.Duration}}</td><td>{{or .LastSuccess "never"}}</td><td class="failed">{{.Err}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Others}}
+<h3>Other users and credentials</h3>
+<table>
+<tr><th>Credentials</th><th>Wakatime URI</th><th>Collector</th><th>Last run</th><th>Duration</th><th>Last success</th><th>Last error</th></tr>
+{{range .Others}}<tr><td>{{or .Credential "default"}}</td><td>{{.URI}}</td><td>{{.Name}}</td><td>{{.LastRun}}</td><td>{{.Duration}}</td><td>{{or .LastSuccess "never"}}</td><td class="failed">{{.Err}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Configuration</h2>
+<table>
+<tr><th>Flag</th><th>Value</th></tr>
+{{range .Flags}}<tr><td>--{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -17,9 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/MacroPower/wakatime_exporter/collector"
 	"github.com/go-kit/kit/log"
@@ -29,24 +33,39 @@ import (
 	"github.com/prometheus/common/version"
 )
 
-// handler wraps an unfiltered http.Handler but uses a filtered handler,
-// created on the fly, if filtering is requested. Create instances with
-// newHandler.
+// handler wraps an unfiltered WakaCollector for each account but uses
+// filtered collectors, created on the fly, if filtering is requested. Each
+// request is served by a registry bound to the request's context. Create
+// instances with newHandler.
 type handler struct {
-	unfilteredHandler http.Handler
+	// mtx protects state, which is replaced when the accounts are reloaded.
+	mtx   sync.RWMutex
+	state *handlerState
 	// exporterMetricsRegistry is a separate registry for the metrics about
 	// the exporter itself.
 	exporterMetricsRegistry *prometheus.Registry
 	includeExporterMetrics  bool
-	commonInputs            collector.CommonInputs
+	backgroundPolling       bool
+	timeoutOffset           time.Duration
 	logger                  log.Logger
 }
 
-func newHandler(commonInputs collector.CommonInputs, includeExporterMetrics bool, logger log.Logger) *handler {
+// handlerState holds the collectors of a set of accounts.
+type handlerState struct {
+	accounts []account
+	// unfilteredCollectors holds the collector of each account.
+	unfilteredCollectors []*collector.WakaCollector
+	// pollers holds the poller of each account's collector in background
+	// polling mode, and is nil otherwise.
+	pollers []*collector.Poller
+}
+
+func newHandler(accounts []account, includeExporterMetrics bool, backgroundPolling bool, timeoutOffset time.Duration, logger log.Logger) (*handler, error) {
 	h := &handler{
 		exporterMetricsRegistry: prometheus.NewRegistry(),
 		includeExporterMetrics:  includeExporterMetrics,
-		commonInputs:            commonInputs,
+		backgroundPolling:       backgroundPolling,
+		timeoutOffset:           timeoutOffset,
 		logger:                  logger,
 	}
 	if h.includeExporterMetrics {
@@ -55,12 +74,66 @@ func newHandler(commonInputs collector.CommonInputs, includeExporterMetrics bool
 			prometheus.NewGoCollector(),
 		)
 	}
-	if innerHandler, err := h.innerHandler(); err != nil {
-		panic(fmt.Sprintf("Couldn't create metrics handler: %s", err))
-	} else {
-		h.unfilteredHandler = innerHandler
+	if err := h.setAccounts(accounts); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// setAccounts replaces the accounts served by the handler. If their
+// collectors can't be created, the previous accounts are kept. Requests
+// already being served complete using the previous accounts.
+func (h *handler) setAccounts(accounts []account) error {
+	ncs, err := h.newCollectors(accounts)
+	if err != nil {
+		return err
+	}
+	state := &handlerState{
+		accounts:             accounts,
+		unfilteredCollectors: ncs,
+	}
+	if h.backgroundPolling {
+		for i, nc := range ncs {
+			logger := h.logger
+			if name := accounts[i].name; name != "" {
+				logger = log.With(logger, "account", name)
+			}
+			p := collector.NewPoller(nc, logger)
+			p.Start()
+			state.pollers = append(state.pollers, p)
+		}
+	}
+
+	h.mtx.Lock()
+	old := h.state
+	h.state = state
+	h.mtx.Unlock()
+
+	if old != nil {
+		for _, p := range old.pollers {
+			p.Stop()
+		}
+	}
+	return nil
+}
+
+// stop stops polling for the accounts currently served, if background
+// polling is enabled.
+func (h *handler) stop() {
+	h.mtx.RLock()
+	state := h.state
+	h.mtx.RUnlock()
+	for _, p := range state.pollers {
+		p.Stop()
 	}
-	return h
+}
+
+// current returns the accounts currently served, and their unfiltered
+// collectors.
+func (h *handler) current() ([]account, []*collector.WakaCollector) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.state.accounts, h.state.unfilteredCollectors
 }
 
 // ServeHTTP implements http.Handler.
@@ -68,51 +141,113 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	filters := r.URL.Query()["collect[]"]
 	level.Debug(h.logger).Log("msg", "collect query:", "filters", filters)
 
-	if len(filters) == 0 {
-		// No filters, use the prepared unfiltered handler.
-		h.unfilteredHandler.ServeHTTP(w, r)
-		return
+	ctx, cancel := scrapeContext(r, h.timeoutOffset, h.logger)
+	defer cancel()
+
+	h.mtx.RLock()
+	state := h.state
+	h.mtx.RUnlock()
+
+	ncs := state.unfilteredCollectors
+	if len(filters) > 0 {
+		// To serve filtered metrics, we create filtering collectors on the fly.
+		var err error
+		ncs, err = h.newCollectors(state.accounts, filters...)
+		if err != nil {
+			level.Warn(h.logger).Log("msg", "Couldn't create filtered metrics handler:", "err", err)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("Couldn't create filtered metrics handler: %s", err)))
+			return
+		}
 	}
-	// To serve filtered metrics, we create a filtering handler on the fly.
-	filteredHandler, err := h.innerHandler(filters...)
+
+	innerHandler, err := h.innerHandler(ctx, state, ncs)
 	if err != nil {
-		level.Warn(h.logger).Log("msg", "Couldn't create filtered metrics handler:", "err", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(fmt.Sprintf("Couldn't create filtered metrics handler: %s", err)))
+		level.Error(h.logger).Log("msg", "Couldn't create metrics handler:", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("Couldn't create metrics handler: %s", err)))
 		return
 	}
-	filteredHandler.ServeHTTP(w, r)
+	innerHandler.ServeHTTP(w, r)
 }
 
-// innerHandler is used to create both the one unfiltered http.Handler to be
-// wrapped by the outer handler and also the filtered handlers created on the
-// fly. The former is accomplished by calling innerHandler without any arguments
-// (in which case it will log all the collectors enabled via command-line
-// flags).
-func (h *handler) innerHandler(filters ...string) (http.Handler, error) {
-	nc, err := collector.NewWakaCollector(h.commonInputs, h.logger, filters...)
+// scrapeContext returns a context for the given scrape request, which is
+// bounded by the scrape timeout sent by Prometheus minus timeoutOffset, so
+// that upstream requests are abandoned before Prometheus gives up.
+func scrapeContext(r *http.Request, timeoutOffset time.Duration, logger log.Logger) (context.Context, context.CancelFunc) {
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return context.WithCancel(r.Context())
+	}
+	seconds, err := strconv.ParseFloat(v, 64)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't create collector: %s", err)
+		level.Warn(logger).Log("msg", "Couldn't parse scrape timeout", "value", v, "err", err)
+		return context.WithCancel(r.Context())
 	}
 
-	// Only log the creation of an unfiltered handler, which should happen
-	// only once upon startup.
-	if len(filters) == 0 {
-		level.Info(h.logger).Log("msg", "Enabled collectors")
-		collectors := []string{}
-		for n := range nc.Collectors {
-			collectors = append(collectors, n)
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > timeoutOffset {
+		timeout -= timeoutOffset
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// newCollectors is used to create both the unfiltered WakaCollectors used by
+// the handler and also the filtered collectors created on the fly, one for
+// each of the given accounts. The former is accomplished by calling
+// newCollectors without any filters (in which case it will log all the
+// enabled collectors).
+func (h *handler) newCollectors(accounts []account, filters ...string) ([]*collector.WakaCollector, error) {
+	ncs := make([]*collector.WakaCollector, 0, len(accounts))
+	for _, a := range accounts {
+		logger := h.logger
+		if a.name != "" {
+			logger = log.With(logger, "account", a.name)
+		}
+		nc, err := collector.NewWakaCollector(a.inputs, logger, filters...)
+		if err != nil {
+			if a.name != "" {
+				return nil, fmt.Errorf("couldn't create collector for account %s: %s", a.name, err)
+			}
+			return nil, fmt.Errorf("couldn't create collector: %s", err)
 		}
-		sort.Strings(collectors)
-		for _, c := range collectors {
-			level.Info(h.logger).Log("collector", c)
+
+		// Only log the creation of an unfiltered collector, which should
+		// happen only upon startup and reloads.
+		if len(filters) == 0 {
+			level.Info(logger).Log("msg", "Enabled collectors")
+			collectors := []string{}
+			for n := range nc.Collectors {
+				collectors = append(collectors, n)
+			}
+			sort.Strings(collectors)
+			for _, c := range collectors {
+				level.Info(logger).Log("collector", c)
+			}
 		}
+		ncs = append(ncs, nc)
 	}
+	return ncs, nil
+}
 
+// innerHandler creates the http.Handler serving the metrics of ncs, the
+// collectors of each account in state, for a single request with the given
+// context.
+func (h *handler) innerHandler(ctx context.Context, state *handlerState, ncs []*collector.WakaCollector) (http.Handler, error) {
 	r := prometheus.NewRegistry()
 	r.MustRegister(version.NewCollector("wakatime_exporter"))
-	if err := r.Register(nc); err != nil {
-		return nil, fmt.Errorf("couldn't register collector: %s", err)
+	r.MustRegister(collector.ExporterMetrics()...)
+	r.MustRegister(reloadMetrics...)
+	for i, nc := range ncs {
+		// In background polling mode, the latest results are served instead
+		// of running the collectors.
+		var c prometheus.Collector = nc.WithContext(ctx)
+		if state.pollers != nil {
+			c = state.pollers[i].Filtered(nc)
+		}
+		if err := prometheus.WrapRegistererWith(state.accounts[i].labels, r).Register(c); err != nil {
+			return nil, fmt.Errorf("couldn't register collector: %s", err)
+		}
 	}
 	handler := promhttp.HandlerFor(
 		prometheus.Gatherers{h.exporterMetricsRegistry, r},
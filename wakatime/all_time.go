@@ -0,0 +1,59 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wakatime
+
+import (
+	"context"
+	"net/url"
+)
+
+// AllTimeSinceTodayEndpoint is the endpoint queried by Client.AllTimeSinceToday.
+const AllTimeSinceTodayEndpoint = "all_time_since_today"
+
+// AllTimeSinceTodayOptions are the parameters of an all_time_since_today
+// request.
+type AllTimeSinceTodayOptions struct {
+	Project string
+	NoCache bool
+}
+
+// AllTimeSinceToday is the response of the all_time_since_today endpoint.
+type AllTimeSinceToday struct {
+	Data AllTime `json:"data"`
+}
+
+// AllTime is the user's total coding time since their account was created.
+type AllTime struct {
+	// IsUpToDate is false while Wakatime is still calculating the total.
+	IsUpToDate   bool    `json:"is_up_to_date"`
+	Text         string  `json:"text"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// AllTimeSinceToday returns the user's total coding time.
+func (c *Client) AllTimeSinceToday(ctx context.Context, opts AllTimeSinceTodayOptions) (*AllTimeSinceToday, error) {
+	params := url.Values{}
+	if opts.Project != "" {
+		params.Set("project", opts.Project)
+	}
+	setCache(params, opts.NoCache)
+
+	allTime := &AllTimeSinceToday{}
+	if err := c.get(ctx, c.UserURI, AllTimeSinceTodayEndpoint, params, allTime); err != nil {
+		return nil, err
+	}
+	return allTime, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package wakatime is a client for the Wakatime API, which is also
+// implemented by compatible servers such as wakapi.
+package wakatime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+// DefaultBaseURI is the base URI of the Wakatime API.
+const DefaultBaseURI = "https://wakatime.com/api/v1"
+
+// CurrentUser refers to the user owning the credentials used by a Client.
+const CurrentUser = "current"
+
+// Fetcher fetches subPath below uri with the given query parameters and
+// returns the response body, which the caller must close. The request is
+// abandoned when ctx is done.
+type Fetcher func(ctx context.Context, uri url.URL, subPath string, params url.Values) (io.ReadCloser, error)
+
+// Decoder decodes a response body from endpoint into v.
+type Decoder func(endpoint string, body io.ReadCloser, v interface{}) error
+
+// Client queries the Wakatime API. Requests are sent via Fetch, which allows
+// callers to add retries, caching and instrumentation.
+type Client struct {
+	// BaseURI is used for endpoints which are not specific to a user.
+	BaseURI url.URL
+	// UserURI is used for endpoints which are specific to a user, and is
+	// usually UserPath(BaseURI, user).
+	UserURI url.URL
+	Fetch   Fetcher
+	// Decode decodes responses. If nil, responses are decoded as JSON.
+	Decode Decoder
+}
+
+// NewClient returns a Client for the given user, sending requests via fetch.
+func NewClient(baseURI url.URL, user string, fetch Fetcher) *Client {
+	return &Client{
+		BaseURI: baseURI,
+		UserURI: UserPath(baseURI, user),
+		Fetch:   fetch,
+	}
+}
+
+// UserPath appends the User path to a given URL
+func UserPath(uri url.URL, user string) url.URL {
+	uri.Path = path.Join(uri.Path, "users", user)
+	return uri
+}
+
+func (c *Client) get(ctx context.Context, uri url.URL, endpoint string, params url.Values, v interface{}) error {
+	body, err := c.Fetch(ctx, uri, endpoint, params)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decode := c.Decode
+	if decode == nil {
+		decode = decodeJSON
+	}
+	return decode(endpoint, body, v)
+}
+
+func decodeJSON(endpoint string, body io.ReadCloser, v interface{}) error {
+	return json.NewDecoder(body).Decode(v)
+}
+
+// setCache disables Wakatime's server-side cache if noCache is set.
+func setCache(params url.Values, noCache bool) {
+	if noCache {
+		params.Set("cache", "false")
+	}
+}
+
+func setPage(params url.Values, page int) {
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page))
+	}
+}
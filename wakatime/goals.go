@@ -0,0 +1,115 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wakatime
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// GoalsEndpoint is the endpoint queried by Client.Goals.
+const GoalsEndpoint = "goals"
+
+// GoalsOptions are the parameters of a goals request.
+type GoalsOptions struct {
+	// Page is the page of goals to return, starting at 1.
+	Page    int
+	NoCache bool
+}
+
+// Goals is the response of the goals endpoint.
+type Goals struct {
+	Data       []Goal `json:"data"`
+	Total      int    `json:"total"`
+	TotalPages int    `json:"total_pages"`
+}
+
+// Goal is a single coding goal.
+type Goal struct {
+	AverageStatus      string           `json:"average_status"`
+	ChartData          []GoalChartData  `json:"chart_data"`
+	CreatedAt          time.Time        `json:"created_at"`
+	CumulativeStatus   string           `json:"cumulative_status"`
+	Delta              string           `json:"delta"`
+	Editors            []interface{}    `json:"editors"`
+	ID                 string           `json:"id"`
+	IgnoreDays         []interface{}    `json:"ignore_days"`
+	IgnoreZeroDays     bool             `json:"ignore_zero_days"`
+	ImproveByPercent   interface{}      `json:"improve_by_percent"`
+	IsCurrentUserOwner bool             `json:"is_current_user_owner"`
+	IsEnabled          bool             `json:"is_enabled"`
+	IsInverse          bool             `json:"is_inverse"`
+	IsSnoozed          bool             `json:"is_snoozed"`
+	IsTweeting         bool             `json:"is_tweeting"`
+	Languages          []string         `json:"languages"`
+	ModifiedAt         interface{}      `json:"modified_at"`
+	Owner              GoalUser         `json:"owner"`
+	Projects           []interface{}    `json:"projects"`
+	RangeText          string           `json:"range_text"`
+	Seconds            int              `json:"seconds"`
+	SharedWith         []interface{}    `json:"shared_with"`
+	SnoozeUntil        interface{}      `json:"snooze_until"`
+	Status             string           `json:"status"`
+	Subscribers        []GoalSubscriber `json:"subscribers"`
+	Title              string           `json:"title"`
+	Type               string           `json:"type"`
+}
+
+// GoalChartData is the progress towards a goal over a single range.
+type GoalChartData struct {
+	ActualSeconds          float64 `json:"actual_seconds"`
+	ActualSecondsText      string  `json:"actual_seconds_text"`
+	GoalSeconds            int     `json:"goal_seconds"`
+	GoalSecondsText        string  `json:"goal_seconds_text"`
+	Range                  Range   `json:"range"`
+	RangeStatus            string  `json:"range_status"`
+	RangeStatusReason      string  `json:"range_status_reason"`
+	RangeStatusReasonShort string  `json:"range_status_reason_short"`
+}
+
+// GoalUser is the owner of a goal.
+type GoalUser struct {
+	DisplayName string      `json:"display_name"`
+	Email       interface{} `json:"email"`
+	FullName    string      `json:"full_name"`
+	ID          string      `json:"id"`
+	Photo       string      `json:"photo"`
+	Username    string      `json:"username"`
+}
+
+// GoalSubscriber is a user subscribed to a goal.
+type GoalSubscriber struct {
+	DisplayName    string      `json:"display_name"`
+	Email          interface{} `json:"email"`
+	EmailFrequency string      `json:"email_frequency"`
+	FullName       string      `json:"full_name"`
+	UserID         string      `json:"user_id"`
+	Username       string      `json:"username"`
+}
+
+// Goals returns a page of the user's goals.
+func (c *Client) Goals(ctx context.Context, opts GoalsOptions) (*Goals, error) {
+	params := url.Values{}
+	setPage(params, opts.Page)
+	setCache(params, opts.NoCache)
+
+	goals := &Goals{}
+	if err := c.get(ctx, c.UserURI, GoalsEndpoint, params, goals); err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
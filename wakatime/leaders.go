@@ -0,0 +1,114 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wakatime
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// LeadersEndpoint is the endpoint queried by Client.Leaders.
+const LeadersEndpoint = "leaders"
+
+// LeadersOptions are the parameters of a leaders request.
+type LeadersOptions struct {
+	// Page is the page of the leaderboard to return, starting at 1.
+	Page int
+	// Language filters the leaderboard by programming language.
+	Language string
+	NoCache  bool
+}
+
+// Leaders is the response of the leaders endpoint.
+type Leaders struct {
+	CurrentUser Leader       `json:"current_user"`
+	Data        []Leader     `json:"data"`
+	Language    interface{}  `json:"language"`
+	ModifiedAt  time.Time    `json:"modified_at"`
+	Page        int          `json:"page"`
+	Range       LeadersRange `json:"range"`
+	Timeout     int          `json:"timeout"`
+	TotalPages  int          `json:"total_pages"`
+	WritesOnly  bool         `json:"writes_only"`
+}
+
+// Leader is a single entry of the leaderboard.
+type Leader struct {
+	Rank         int          `json:"rank"`
+	RunningTotal RunningTotal `json:"running_total"`
+	User         LeaderUser   `json:"user"`
+}
+
+// RunningTotal is a user's coding activity over the leaderboard's range.
+type RunningTotal struct {
+	DailyAverage              int             `json:"daily_average"`
+	HumanReadableDailyAverage string          `json:"human_readable_daily_average"`
+	HumanReadableTotal        string          `json:"human_readable_total"`
+	Languages                 []LanguageTotal `json:"languages"`
+	// ModifiedAt is only set for the current user.
+	ModifiedAt   time.Time `json:"modified_at"`
+	TotalSeconds float64   `json:"total_seconds"`
+}
+
+// LanguageTotal is the time spent on a single language.
+type LanguageTotal struct {
+	Name         string  `json:"name"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// LeaderUser is the public profile of a user on the leaderboard.
+type LeaderUser struct {
+	DisplayName          string `json:"display_name"`
+	Email                string `json:"email"`
+	FullName             string `json:"full_name"`
+	HumanReadableWebsite string `json:"human_readable_website"`
+	ID                   string `json:"id"`
+	IsEmailPublic        bool   `json:"is_email_public"`
+	IsHireable           bool   `json:"is_hireable"`
+	Location             string `json:"location"`
+	Photo                string `json:"photo"`
+	PhotoPublic          bool   `json:"photo_public"`
+	Username             string `json:"username"`
+	Website              string `json:"website"`
+}
+
+// LeadersRange is the time range covered by the leaderboard.
+type LeadersRange struct {
+	EndDate   string `json:"end_date"`
+	EndText   string `json:"end_text"`
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	StartText string `json:"start_text"`
+	Text      string `json:"text"`
+}
+
+// Leaders returns a page of the public leaderboard, including the current
+// user's rank.
+func (c *Client) Leaders(ctx context.Context, opts LeadersOptions) (*Leaders, error) {
+	params := url.Values{}
+	setPage(params, opts.Page)
+	if opts.Language != "" {
+		params.Set("language", opts.Language)
+	}
+	setCache(params, opts.NoCache)
+
+	leaders := &Leaders{}
+	if err := c.get(ctx, c.BaseURI, LeadersEndpoint, params, leaders); err != nil {
+		return nil, err
+	}
+	return leaders, nil
+}
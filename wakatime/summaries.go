@@ -0,0 +1,115 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wakatime
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// SummariesEndpoint is the endpoint queried by Client.Summaries.
+const SummariesEndpoint = "summaries"
+
+// SummariesOptions are the parameters of a summaries request.
+type SummariesOptions struct {
+	// Start and End are dates, or relative dates such as "today".
+	Start    string
+	End      string
+	Project  string
+	Branches string
+	Timezone string
+	NoCache  bool
+}
+
+// Summaries is the response of the summaries endpoint.
+type Summaries struct {
+	Data  []Summary `json:"data"`
+	End   time.Time `json:"end"`
+	Start time.Time `json:"start"`
+}
+
+// Summary is the coding activity for a single day.
+type Summary struct {
+	Categories       []SummaryItem        `json:"categories"`
+	Dependencies     []SummaryItem        `json:"dependencies"`
+	Editors          []SummaryItem        `json:"editors"`
+	GrandTotal       GrandTotal           `json:"grand_total"`
+	Languages        []SummaryItem        `json:"languages"`
+	Machines         []MachineSummaryItem `json:"machines"`
+	OperatingSystems []SummaryItem        `json:"operating_systems"`
+	Projects         []SummaryItem        `json:"projects"`
+	Range            Range                `json:"range"`
+}
+
+// SummaryItem is the time spent on a single language, editor, project, etc.
+type SummaryItem struct {
+	Digital      string  `json:"digital"`
+	Hours        int     `json:"hours"`
+	Minutes      int     `json:"minutes"`
+	Name         string  `json:"name"`
+	Percent      float64 `json:"percent"`
+	Seconds      int     `json:"seconds"`
+	Text         string  `json:"text"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// MachineSummaryItem is the time spent on a single machine.
+type MachineSummaryItem struct {
+	SummaryItem
+	MachineNameID string `json:"machine_name_id"`
+}
+
+// GrandTotal is the total time spent in a summary.
+type GrandTotal struct {
+	Digital      string  `json:"digital"`
+	Hours        int     `json:"hours"`
+	Minutes      int     `json:"minutes"`
+	Text         string  `json:"text"`
+	TotalSeconds float64 `json:"total_seconds"`
+}
+
+// Range is the time range covered by a summary or goal.
+type Range struct {
+	Date     string    `json:"date"`
+	End      time.Time `json:"end"`
+	Start    time.Time `json:"start"`
+	Text     string    `json:"text"`
+	Timezone string    `json:"timezone"`
+}
+
+// Summaries returns the user's coding activity for the given range of days.
+func (c *Client) Summaries(ctx context.Context, opts SummariesOptions) (*Summaries, error) {
+	params := url.Values{}
+	params.Set("start", opts.Start)
+	params.Set("end", opts.End)
+	if opts.Project != "" {
+		params.Set("project", opts.Project)
+	}
+	if opts.Branches != "" {
+		params.Set("branches", opts.Branches)
+	}
+	if opts.Timezone != "" {
+		params.Set("timezone", opts.Timezone)
+	}
+	setCache(params, opts.NoCache)
+
+	summaries := &Summaries{}
+	if err := c.get(ctx, c.UserURI, SummariesEndpoint, params, summaries); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
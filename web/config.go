@@ -0,0 +1,168 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package web serves the exporter's endpoints over TLS and with basic
+// authentication, as described by a web config file in the format of the
+// Prometheus exporter toolkit.
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the format of the web config file.
+type Config struct {
+	TLSConfig TLSConfig `yaml:"tls_server_config"`
+	// Users maps the name of each user allowed to access the exporter to
+	// the bcrypt hash of their password. If empty, no authentication is
+	// required.
+	Users map[string]string `yaml:"basic_auth_users"`
+}
+
+// TLSConfig configures the TLS connections accepted by the exporter. TLS is
+// only enabled if CertFile and KeyFile are set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientAuth is the name of a tls.ClientAuthType, e.g.
+	// RequireAndVerifyClientCert.
+	ClientAuth   string `yaml:"client_auth_type"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	MinVersion   string `yaml:"min_version"`
+	MaxVersion   string `yaml:"max_version"`
+}
+
+// clientAuthTypes maps the accepted names of client authentication types to
+// their values.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// tlsVersions maps the accepted names of TLS versions to their values.
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// LoadConfig reads and validates the web config file at path. Relative paths
+// in the file are relative to the directory containing it.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("couldn't parse %s: %s", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid web config %s: %s", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&c.TLSConfig.CertFile, &c.TLSConfig.KeyFile, &c.TLSConfig.ClientCAFile} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	t := c.TLSConfig
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("cert_file and key_file must be set together")
+	}
+	clientAuth, ok := clientAuthTypes[t.ClientAuth]
+	if !ok {
+		return fmt.Errorf("invalid client_auth_type %q", t.ClientAuth)
+	}
+	if t.CertFile == "" && (clientAuth != tls.NoClientCert || t.ClientCAFile != "" || t.MinVersion != "" || t.MaxVersion != "") {
+		return errors.New("tls_server_config requires cert_file and key_file")
+	}
+	if t.ClientCAFile != "" && clientAuth == tls.NoClientCert {
+		return errors.New("client_ca_file requires a client_auth_type other than NoClientCert")
+	}
+	if t.ClientCAFile == "" && (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) {
+		return fmt.Errorf("client_auth_type %s requires client_ca_file", t.ClientAuth)
+	}
+	for _, v := range []string{t.MinVersion, t.MaxVersion} {
+		if _, ok := tlsVersions[v]; v != "" && !ok {
+			return fmt.Errorf("invalid TLS version %q", v)
+		}
+	}
+	if t.MinVersion != "" && t.MaxVersion != "" && tlsVersions[t.MinVersion] > tlsVersions[t.MaxVersion] {
+		return errors.New("min_version is greater than max_version")
+	}
+
+	for user, hash := range c.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("invalid password hash for user %s: %s", user, err)
+		}
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration described by c, or nil if TLS is
+// disabled.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	t := c.TLSConfig
+	if t.CertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load certificate: %s", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuthTypes[t.ClientAuth],
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.MinVersion != "" {
+		cfg.MinVersion = tlsVersions[t.MinVersion]
+	}
+	if t.MaxVersion != "" {
+		cfg.MaxVersion = tlsVersions[t.MaxVersion]
+	}
+	if t.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read client CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", t.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+	}
+	return cfg, nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ListenAndServe listens on server.Addr and calls Serve.
+func ListenAndServe(server *http.Server, configPath string, logger log.Logger) error {
+	addr := server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return Serve(l, server, configPath, logger)
+}
+
+// Serve serves server on l, as described by the web config file at
+// configPath. If configPath is empty, plain HTTP is served without
+// authentication. The file is read again for every TLS handshake and request,
+// so that certificates and users can be changed without a restart.
+func Serve(l net.Listener, server *http.Server, configPath string, logger log.Logger) error {
+	if configPath == "" {
+		level.Info(logger).Log("msg", "TLS is disabled")
+		return server.Serve(l)
+	}
+
+	c, err := LoadConfig(configPath)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	handler := server.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	server.Handler = &authHandler{
+		handler:    handler,
+		configPath: configPath,
+		logger:     logger,
+		cache:      make(map[[sha256.Size]byte]bool),
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		l.Close()
+		return err
+	}
+	if tlsConfig == nil {
+		level.Info(logger).Log("msg", "TLS is disabled")
+		return server.Serve(l)
+	}
+
+	level.Info(logger).Log("msg", "TLS is enabled")
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c, err := LoadConfig(configPath)
+		if err != nil {
+			level.Error(logger).Log("msg", "Error loading web config", "err", err)
+			return nil, err
+		}
+		cfg, err := c.tlsConfig()
+		if err == nil && cfg == nil {
+			// TLS can't be disabled once the server is listening.
+			return nil, nil
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "Error loading web config", "err", err)
+		}
+		return cfg, err
+	}
+	server.TLSConfig = tlsConfig
+	return server.ServeTLS(l, "", "")
+}
+
+// authHandler requires requests to authenticate as one of the users of the
+// web config file at configPath, if any, before passing them to handler.
+type authHandler struct {
+	handler    http.Handler
+	configPath string
+	logger     log.Logger
+
+	// cache holds the hashes of user names, password hashes and passwords
+	// which were successfully authenticated, since checking bcrypt hashes is
+	// deliberately slow.
+	mtx   sync.Mutex
+	cache map[[sha256.Size]byte]bool
+}
+
+// dummyHash is compared against the passwords of unknown users, so that
+// they take as long to reject as wrong passwords.
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// ServeHTTP implements http.Handler.
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := LoadConfig(h.configPath)
+	if err != nil {
+		level.Error(h.logger).Log("msg", "Error loading web config", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if len(c.Users) == 0 {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if user, password, ok := r.BasicAuth(); ok {
+		if h.authenticate(user, c.Users[user], password) {
+			h.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	w.Header().Set("WWW-Authenticate", "Basic")
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// authenticate reports whether password matches hash. Unknown users have an
+// empty hash.
+func (h *authHandler) authenticate(user, hash, password string) bool {
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	h.mtx.Lock()
+	ok := h.cache[key]
+	h.mtx.Unlock()
+	if ok {
+		return true
+	}
+
+	if hash == "" {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	h.mtx.Lock()
+	h.cache[key] = true
+	h.mtx.Unlock()
+	return true
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2020 Jacob Colvin (MacroPower)
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wakatime_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key to
+// dir, as cert.pem and key.pem.
+func writeCert(t *testing.T, dir string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "wakatime_exporter"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "cert.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, dir, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return cert
+}
+
+// serve serves a handler responding with 200 OK, as described by the web
+// config file at configPath, and returns its address.
+func serve(t *testing.T, configPath string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go Serve(l, server, configPath, log.NewNopLogger())
+	t.Cleanup(func() { server.Close() })
+	return l.Addr().String()
+}
+
+func TestLoadConfigExample(t *testing.T) {
+	c, err := LoadConfig("../docs/example/web-config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(c.Users["prometheus"]), []byte("changeme")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field": `tls_server_config: {cert: x}`,
+		"key missing":   `tls_server_config: {cert_file: x}`,
+		"client auth":   `tls_server_config: {cert_file: x, key_file: y, client_auth_type: Always}`,
+		"no tls":        `tls_server_config: {client_auth_type: RequireAnyClientCert}`,
+		"ca file":       `tls_server_config: {cert_file: x, key_file: y, client_ca_file: z}`,
+		"no ca file":    `tls_server_config: {cert_file: x, key_file: y, client_auth_type: RequireAndVerifyClientCert}`,
+		"version":       `tls_server_config: {cert_file: x, key_file: y, min_version: SSL30}`,
+		"versions":      `tls_server_config: {cert_file: x, key_file: y, min_version: TLS13, max_version: TLS12}`,
+		"hash":          `basic_auth_users: {alice: secret}`,
+	}
+	for name, config := range tests {
+		if _, err := LoadConfig(writeFile(t, tempDir(t), "web.yaml", config)); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestLoadConfigRelativePaths(t *testing.T) {
+	dir := tempDir(t)
+	c, err := LoadConfig(writeFile(t, dir, "web.yaml", `tls_server_config: {cert_file: cert.pem, key_file: /key.pem}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TLSConfig.CertFile != filepath.Join(dir, "cert.pem") || c.TLSConfig.KeyFile != "/key.pem" {
+		t.Errorf("unexpected paths: %+v", c.TLSConfig)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serve(t, writeFile(t, tempDir(t), "web.yaml", "basic_auth_users:\n  alice: "+string(hash)+"\n"))
+
+	tests := []struct {
+		user, password string
+		want           int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"alice", "wrong", http.StatusUnauthorized},
+		{"bob", "hunter2", http.StatusUnauthorized},
+		{"alice", "hunter2", http.StatusOK},
+		// Successful logins are cached.
+		{"alice", "hunter2", http.StatusOK},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.user != "" {
+			req.SetBasicAuth(test.user, test.password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.want {
+			t.Errorf("%s:%s: got status %d, want %d", test.user, test.password, resp.StatusCode, test.want)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	dir := tempDir(t)
+	cert := writeCert(t, dir)
+	addr := serve(t, writeFile(t, dir, "web.yaml", `
+tls_server_config:
+  cert_file: cert.pem
+  key_file: key.pem
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: cert.pem
+`))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(tlsConfig *tls.Config) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get("https://" + addr + "/metrics")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("got status %d", resp.StatusCode)
+		}
+		return nil
+	}
+	if err := get(&tls.Config{RootCAs: roots}); err == nil {
+		t.Error("got no error without a client certificate")
+	}
+	if err := get(&tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}); err != nil {
+		t.Error(err)
+	}
+}